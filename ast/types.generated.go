@@ -9,20 +9,31 @@ import (
 
 type Expression interface {
 	Accept(visitor ExprVisitor) (result interface{}, err error)
+	// Span returns where the expression locates in source.
+	Span() token.Span
 }
 
 type Statement interface {
 	Accept(visitor StmtVisitor) (err error)
+	// Span returns where the statement locates in source.
+	Span() token.Span
 }
 
 type ExprVisitor interface {
 	VisitAssignExpr(v *AssignExpr) (result interface{}, err error)
 	VisitBinaryExpr(v *BinaryExpr) (result interface{}, err error)
 	VisitCallExpr(v *CallExpr) (result interface{}, err error)
+	VisitConditionalExpr(v *ConditionalExpr) (result interface{}, err error)
+	VisitFunctionExpr(v *FunctionExpr) (result interface{}, err error)
 	VisitGetExpr(v *GetExpr) (result interface{}, err error)
 	VisitGroupingExpr(v *GroupingExpr) (result interface{}, err error)
+	VisitIndexGetExpr(v *IndexGetExpr) (result interface{}, err error)
+	VisitIndexSetExpr(v *IndexSetExpr) (result interface{}, err error)
+	VisitInterpolationExpr(v *InterpolationExpr) (result interface{}, err error)
+	VisitListExpr(v *ListExpr) (result interface{}, err error)
 	VisitLiteralExpr(v *LiteralExpr) (result interface{}, err error)
 	VisitLogicalExpr(v *LogicalExpr) (result interface{}, err error)
+	VisitMapExpr(v *MapExpr) (result interface{}, err error)
 	VisitSetExpr(v *SetExpr) (result interface{}, err error)
 	VisitSuperExpr(v *SuperExpr) (result interface{}, err error)
 	VisitThisExpr(v *ThisExpr) (result interface{}, err error)
@@ -46,6 +57,14 @@ func (s StubExprVisitor) VisitCallExpr(_ *CallExpr) (interface{}, error) {
 	return nil, errors.New("visit func for CallExpr is not implemented")
 }
 
+func (s StubExprVisitor) VisitConditionalExpr(_ *ConditionalExpr) (interface{}, error) {
+	return nil, errors.New("visit func for ConditionalExpr is not implemented")
+}
+
+func (s StubExprVisitor) VisitFunctionExpr(_ *FunctionExpr) (interface{}, error) {
+	return nil, errors.New("visit func for FunctionExpr is not implemented")
+}
+
 func (s StubExprVisitor) VisitGetExpr(_ *GetExpr) (interface{}, error) {
 	return nil, errors.New("visit func for GetExpr is not implemented")
 }
@@ -54,6 +73,22 @@ func (s StubExprVisitor) VisitGroupingExpr(_ *GroupingExpr) (interface{}, error)
 	return nil, errors.New("visit func for GroupingExpr is not implemented")
 }
 
+func (s StubExprVisitor) VisitIndexGetExpr(_ *IndexGetExpr) (interface{}, error) {
+	return nil, errors.New("visit func for IndexGetExpr is not implemented")
+}
+
+func (s StubExprVisitor) VisitIndexSetExpr(_ *IndexSetExpr) (interface{}, error) {
+	return nil, errors.New("visit func for IndexSetExpr is not implemented")
+}
+
+func (s StubExprVisitor) VisitInterpolationExpr(_ *InterpolationExpr) (interface{}, error) {
+	return nil, errors.New("visit func for InterpolationExpr is not implemented")
+}
+
+func (s StubExprVisitor) VisitListExpr(_ *ListExpr) (interface{}, error) {
+	return nil, errors.New("visit func for ListExpr is not implemented")
+}
+
 func (s StubExprVisitor) VisitLiteralExpr(_ *LiteralExpr) (interface{}, error) {
 	return nil, errors.New("visit func for LiteralExpr is not implemented")
 }
@@ -62,6 +97,10 @@ func (s StubExprVisitor) VisitLogicalExpr(_ *LogicalExpr) (interface{}, error) {
 	return nil, errors.New("visit func for LogicalExpr is not implemented")
 }
 
+func (s StubExprVisitor) VisitMapExpr(_ *MapExpr) (interface{}, error) {
+	return nil, errors.New("visit func for MapExpr is not implemented")
+}
+
 func (s StubExprVisitor) VisitSetExpr(_ *SetExpr) (interface{}, error) {
 	return nil, errors.New("visit func for SetExpr is not implemented")
 }
@@ -82,9 +121,12 @@ func (s StubExprVisitor) VisitVariableExpr(_ *VariableExpr) (interface{}, error)
 	return nil, errors.New("visit func for VariableExpr is not implemented")
 }
 
+// Operator is nil for "=", or one of compound assignments like "+=".
 type AssignExpr struct {
-	Name  *token.Token
-	Value Expression
+	Name     *token.Token
+	Operator *token.Token
+	Value    Expression
+	Range    token.Span
 }
 
 var _ Expression = (*AssignExpr)(nil)
@@ -93,10 +135,15 @@ func (b *AssignExpr) Accept(visitor ExprVisitor) (result interface{}, err error)
 	return visitor.VisitAssignExpr(b)
 }
 
+func (b *AssignExpr) Span() token.Span {
+	return b.Range
+}
+
 type BinaryExpr struct {
 	Left     Expression
 	Operator *token.Token
 	Right    Expression
+	Range    token.Span
 }
 
 var _ Expression = (*BinaryExpr)(nil)
@@ -105,10 +152,15 @@ func (b *BinaryExpr) Accept(visitor ExprVisitor) (result interface{}, err error)
 	return visitor.VisitBinaryExpr(b)
 }
 
+func (b *BinaryExpr) Span() token.Span {
+	return b.Range
+}
+
 type CallExpr struct {
 	Callee    Expression
 	Paren     *token.Token
 	Arguments []Expression
+	Range     token.Span
 }
 
 var _ Expression = (*CallExpr)(nil)
@@ -117,9 +169,50 @@ func (b *CallExpr) Accept(visitor ExprVisitor) (result interface{}, err error) {
 	return visitor.VisitCallExpr(b)
 }
 
+func (b *CallExpr) Span() token.Span {
+	return b.Range
+}
+
+// ConditionalExpr is the ternary operator "?:".
+type ConditionalExpr struct {
+	Condition  Expression
+	ThenBranch Expression
+	ElseBranch Expression
+	Range      token.Span
+}
+
+var _ Expression = (*ConditionalExpr)(nil)
+
+func (b *ConditionalExpr) Accept(visitor ExprVisitor) (result interface{}, err error) {
+	return visitor.VisitConditionalExpr(b)
+}
+
+func (b *ConditionalExpr) Span() token.Span {
+	return b.Range
+}
+
+// FunctionExpr is an anonymous function.
+type FunctionExpr struct {
+	Keyword *token.Token
+	Params  []*token.Token
+	Body    []Statement
+	Range   token.Span
+}
+
+var _ Expression = (*FunctionExpr)(nil)
+
+func (b *FunctionExpr) Accept(visitor ExprVisitor) (result interface{}, err error) {
+	return visitor.VisitFunctionExpr(b)
+}
+
+func (b *FunctionExpr) Span() token.Span {
+	return b.Range
+}
+
 type GetExpr struct {
 	Object Expression
 	Name   *token.Token
+	Range  token.Span
 }
 
 var _ Expression = (*GetExpr)(nil)
@@ -128,8 +221,13 @@ func (b *GetExpr) Accept(visitor ExprVisitor) (result interface{}, err error) {
 	return visitor.VisitGetExpr(b)
 }
 
+func (b *GetExpr) Span() token.Span {
+	return b.Range
+}
+
 type GroupingExpr struct {
-	Expr Expression
+	Expr  Expression
+	Range token.Span
 }
 
 var _ Expression = (*GroupingExpr)(nil)
@@ -138,8 +236,81 @@ func (b *GroupingExpr) Accept(visitor ExprVisitor) (result interface{}, err erro
 	return visitor.VisitGroupingExpr(b)
 }
 
+func (b *GroupingExpr) Span() token.Span {
+	return b.Range
+}
+
+type IndexGetExpr struct {
+	Object  Expression
+	Bracket *token.Token
+	Index   Expression
+	Range   token.Span
+}
+
+var _ Expression = (*IndexGetExpr)(nil)
+
+func (b *IndexGetExpr) Accept(visitor ExprVisitor) (result interface{}, err error) {
+	return visitor.VisitIndexGetExpr(b)
+}
+
+func (b *IndexGetExpr) Span() token.Span {
+	return b.Range
+}
+
+// Operator is nil for "=", or one of compound assignments like "+=".
+type IndexSetExpr struct {
+	Object   Expression
+	Bracket  *token.Token
+	Index    Expression
+	Operator *token.Token
+	Value    Expression
+	Range    token.Span
+}
+
+var _ Expression = (*IndexSetExpr)(nil)
+
+func (b *IndexSetExpr) Accept(visitor ExprVisitor) (result interface{}, err error) {
+	return visitor.VisitIndexSetExpr(b)
+}
+
+func (b *IndexSetExpr) Span() token.Span {
+	return b.Range
+}
+
+// InterpolationExpr is a string with embedded expressions, Parts are joined after stringified.
+type InterpolationExpr struct {
+	Parts []Expression
+	Range token.Span
+}
+
+var _ Expression = (*InterpolationExpr)(nil)
+
+func (b *InterpolationExpr) Accept(visitor ExprVisitor) (result interface{}, err error) {
+	return visitor.VisitInterpolationExpr(b)
+}
+
+func (b *InterpolationExpr) Span() token.Span {
+	return b.Range
+}
+
+type ListExpr struct {
+	Elements []Expression
+	Range    token.Span
+}
+
+var _ Expression = (*ListExpr)(nil)
+
+func (b *ListExpr) Accept(visitor ExprVisitor) (result interface{}, err error) {
+	return visitor.VisitListExpr(b)
+}
+
+func (b *ListExpr) Span() token.Span {
+	return b.Range
+}
+
 type LiteralExpr struct {
 	Value interface{}
+	Range token.Span
 }
 
 var _ Expression = (*LiteralExpr)(nil)
@@ -148,10 +319,15 @@ func (b *LiteralExpr) Accept(visitor ExprVisitor) (result interface{}, err error
 	return visitor.VisitLiteralExpr(b)
 }
 
+func (b *LiteralExpr) Span() token.Span {
+	return b.Range
+}
+
 type LogicalExpr struct {
 	Left     Expression
 	Operator *token.Token
 	Right    Expression
+	Range    token.Span
 }
 
 var _ Expression = (*LogicalExpr)(nil)
@@ -160,10 +336,35 @@ func (b *LogicalExpr) Accept(visitor ExprVisitor) (result interface{}, err error
 	return visitor.VisitLogicalExpr(b)
 }
 
+func (b *LogicalExpr) Span() token.Span {
+	return b.Range
+}
+
+// MapExpr is a map literal, Keys and Values are paired by index.
+type MapExpr struct {
+	Brace  *token.Token
+	Keys   []Expression
+	Values []Expression
+	Range  token.Span
+}
+
+var _ Expression = (*MapExpr)(nil)
+
+func (b *MapExpr) Accept(visitor ExprVisitor) (result interface{}, err error) {
+	return visitor.VisitMapExpr(b)
+}
+
+func (b *MapExpr) Span() token.Span {
+	return b.Range
+}
+
+// Operator is nil for "=", or one of compound assignments like "+=".
 type SetExpr struct {
-	Object Expression
-	Name   *token.Token
-	Value  Expression
+	Object   Expression
+	Name     *token.Token
+	Operator *token.Token
+	Value    Expression
+	Range    token.Span
 }
 
 var _ Expression = (*SetExpr)(nil)
@@ -172,9 +373,14 @@ func (b *SetExpr) Accept(visitor ExprVisitor) (result interface{}, err error) {
 	return visitor.VisitSetExpr(b)
 }
 
+func (b *SetExpr) Span() token.Span {
+	return b.Range
+}
+
 type SuperExpr struct {
 	Keyword *token.Token
 	Method  *token.Token
+	Range   token.Span
 }
 
 var _ Expression = (*SuperExpr)(nil)
@@ -183,8 +389,13 @@ func (b *SuperExpr) Accept(visitor ExprVisitor) (result interface{}, err error)
 	return visitor.VisitSuperExpr(b)
 }
 
+func (b *SuperExpr) Span() token.Span {
+	return b.Range
+}
+
 type ThisExpr struct {
 	Keyword *token.Token
+	Range   token.Span
 }
 
 var _ Expression = (*ThisExpr)(nil)
@@ -193,9 +404,14 @@ func (b *ThisExpr) Accept(visitor ExprVisitor) (result interface{}, err error) {
 	return visitor.VisitThisExpr(b)
 }
 
+func (b *ThisExpr) Span() token.Span {
+	return b.Range
+}
+
 type UnaryExpr struct {
 	Operator *token.Token
 	Right    Expression
+	Range    token.Span
 }
 
 var _ Expression = (*UnaryExpr)(nil)
@@ -204,8 +420,13 @@ func (b *UnaryExpr) Accept(visitor ExprVisitor) (result interface{}, err error)
 	return visitor.VisitUnaryExpr(b)
 }
 
+func (b *UnaryExpr) Span() token.Span {
+	return b.Range
+}
+
 type VariableExpr struct {
-	Name *token.Token
+	Name  *token.Token
+	Range token.Span
 }
 
 var _ Expression = (*VariableExpr)(nil)
@@ -214,14 +435,24 @@ func (b *VariableExpr) Accept(visitor ExprVisitor) (result interface{}, err erro
 	return visitor.VisitVariableExpr(b)
 }
 
+func (b *VariableExpr) Span() token.Span {
+	return b.Range
+}
+
 type StmtVisitor interface {
 	VisitBlockStmt(v *BlockStmt) (err error)
+	VisitBreakStmt(v *BreakStmt) (err error)
 	VisitClassStmt(v *ClassStmt) (err error)
+	VisitContinueStmt(v *ContinueStmt) (err error)
+	VisitExportStmt(v *ExportStmt) (err error)
 	VisitExprStmt(v *ExprStmt) (err error)
 	VisitFunctionStmt(v *FunctionStmt) (err error)
 	VisitIfStmt(v *IfStmt) (err error)
+	VisitImportStmt(v *ImportStmt) (err error)
 	VisitPrintStmt(v *PrintStmt) (err error)
 	VisitReturnStmt(v *ReturnStmt) (err error)
+	VisitThrowStmt(v *ThrowStmt) (err error)
+	VisitTryStmt(v *TryStmt) (err error)
 	VisitVarStmt(v *VarStmt) (err error)
 	VisitWhileStmt(v *WhileStmt) (err error)
 }
@@ -234,10 +465,22 @@ func (s StubExprVisitor) VisitBlockStmt(_ *BlockStmt) error {
 	return errors.New("visit func for BlockStmt is not implemented")
 }
 
+func (s StubExprVisitor) VisitBreakStmt(_ *BreakStmt) error {
+	return errors.New("visit func for BreakStmt is not implemented")
+}
+
 func (s StubExprVisitor) VisitClassStmt(_ *ClassStmt) error {
 	return errors.New("visit func for ClassStmt is not implemented")
 }
 
+func (s StubExprVisitor) VisitContinueStmt(_ *ContinueStmt) error {
+	return errors.New("visit func for ContinueStmt is not implemented")
+}
+
+func (s StubExprVisitor) VisitExportStmt(_ *ExportStmt) error {
+	return errors.New("visit func for ExportStmt is not implemented")
+}
+
 func (s StubExprVisitor) VisitExprStmt(_ *ExprStmt) error {
 	return errors.New("visit func for ExprStmt is not implemented")
 }
@@ -250,6 +493,10 @@ func (s StubExprVisitor) VisitIfStmt(_ *IfStmt) error {
 	return errors.New("visit func for IfStmt is not implemented")
 }
 
+func (s StubExprVisitor) VisitImportStmt(_ *ImportStmt) error {
+	return errors.New("visit func for ImportStmt is not implemented")
+}
+
 func (s StubExprVisitor) VisitPrintStmt(_ *PrintStmt) error {
 	return errors.New("visit func for PrintStmt is not implemented")
 }
@@ -258,6 +505,14 @@ func (s StubExprVisitor) VisitReturnStmt(_ *ReturnStmt) error {
 	return errors.New("visit func for ReturnStmt is not implemented")
 }
 
+func (s StubExprVisitor) VisitThrowStmt(_ *ThrowStmt) error {
+	return errors.New("visit func for ThrowStmt is not implemented")
+}
+
+func (s StubExprVisitor) VisitTryStmt(_ *TryStmt) error {
+	return errors.New("visit func for TryStmt is not implemented")
+}
+
 func (s StubExprVisitor) VisitVarStmt(_ *VarStmt) error {
 	return errors.New("visit func for VarStmt is not implemented")
 }
@@ -268,6 +523,7 @@ func (s StubExprVisitor) VisitWhileStmt(_ *WhileStmt) error {
 
 type BlockStmt struct {
 	Stmts []Statement
+	Range token.Span
 }
 
 var _ Statement = (*BlockStmt)(nil)
@@ -276,10 +532,32 @@ func (b *BlockStmt) Accept(visitor StmtVisitor) (err error) {
 	return visitor.VisitBlockStmt(b)
 }
 
+func (b *BlockStmt) Span() token.Span {
+	return b.Range
+}
+
+type BreakStmt struct {
+	Keyword *token.Token
+	Range   token.Span
+}
+
+var _ Statement = (*BreakStmt)(nil)
+
+func (b *BreakStmt) Accept(visitor StmtVisitor) (err error) {
+	return visitor.VisitBreakStmt(b)
+}
+
+func (b *BreakStmt) Span() token.Span {
+	return b.Range
+}
+
+// StaticMethods are declared with "class" in the class body, and are called on the class itself.
 type ClassStmt struct {
-	Name       *token.Token
-	SuperClass *VariableExpr
-	Methods    []*FunctionStmt
+	Name          *token.Token
+	SuperClass    *VariableExpr
+	Methods       []*FunctionStmt
+	StaticMethods []*FunctionStmt
+	Range         token.Span
 }
 
 var _ Statement = (*ClassStmt)(nil)
@@ -288,8 +566,45 @@ func (b *ClassStmt) Accept(visitor StmtVisitor) (err error) {
 	return visitor.VisitClassStmt(b)
 }
 
+func (b *ClassStmt) Span() token.Span {
+	return b.Range
+}
+
+type ContinueStmt struct {
+	Keyword *token.Token
+	Range   token.Span
+}
+
+var _ Statement = (*ContinueStmt)(nil)
+
+func (b *ContinueStmt) Accept(visitor StmtVisitor) (err error) {
+	return visitor.VisitContinueStmt(b)
+}
+
+func (b *ContinueStmt) Span() token.Span {
+	return b.Range
+}
+
+// ExportStmt makes the class, function or variable declared by Decl visible to importers.
+type ExportStmt struct {
+	Keyword *token.Token
+	Decl    Statement
+	Range   token.Span
+}
+
+var _ Statement = (*ExportStmt)(nil)
+
+func (b *ExportStmt) Accept(visitor StmtVisitor) (err error) {
+	return visitor.VisitExportStmt(b)
+}
+
+func (b *ExportStmt) Span() token.Span {
+	return b.Range
+}
+
 type ExprStmt struct {
-	Expr Expression
+	Expr  Expression
+	Range token.Span
 }
 
 var _ Statement = (*ExprStmt)(nil)
@@ -298,10 +613,17 @@ func (b *ExprStmt) Accept(visitor StmtVisitor) (err error) {
 	return visitor.VisitExprStmt(b)
 }
 
+func (b *ExprStmt) Span() token.Span {
+	return b.Range
+}
+
+// IsGetter is set for methods declared without a parameter list, which run when the property is accessed.
 type FunctionStmt struct {
-	Name   *token.Token
-	Params []*token.Token
-	Body   []Statement
+	Name     *token.Token
+	Params   []*token.Token
+	Body     []Statement
+	IsGetter bool
+	Range    token.Span
 }
 
 var _ Statement = (*FunctionStmt)(nil)
@@ -310,10 +632,15 @@ func (b *FunctionStmt) Accept(visitor StmtVisitor) (err error) {
 	return visitor.VisitFunctionStmt(b)
 }
 
+func (b *FunctionStmt) Span() token.Span {
+	return b.Range
+}
+
 type IfStmt struct {
 	Condition  Expression
 	ThenBranch Statement
 	ElseBranch Statement
+	Range      token.Span
 }
 
 var _ Statement = (*IfStmt)(nil)
@@ -322,8 +649,31 @@ func (b *IfStmt) Accept(visitor StmtVisitor) (err error) {
 	return visitor.VisitIfStmt(b)
 }
 
+func (b *IfStmt) Span() token.Span {
+	return b.Range
+}
+
+// ImportStmt imports Names exported by the module at Path, or all of them if Names is nil.
+type ImportStmt struct {
+	Keyword *token.Token
+	Names   []*token.Token
+	Path    *token.Token
+	Range   token.Span
+}
+
+var _ Statement = (*ImportStmt)(nil)
+
+func (b *ImportStmt) Accept(visitor StmtVisitor) (err error) {
+	return visitor.VisitImportStmt(b)
+}
+
+func (b *ImportStmt) Span() token.Span {
+	return b.Range
+}
+
 type PrintStmt struct {
-	Expr Expression
+	Expr  Expression
+	Range token.Span
 }
 
 var _ Statement = (*PrintStmt)(nil)
@@ -332,9 +682,14 @@ func (b *PrintStmt) Accept(visitor StmtVisitor) (err error) {
 	return visitor.VisitPrintStmt(b)
 }
 
+func (b *PrintStmt) Span() token.Span {
+	return b.Range
+}
+
 type ReturnStmt struct {
 	Keyword *token.Token
 	Value   Expression
+	Range   token.Span
 }
 
 var _ Statement = (*ReturnStmt)(nil)
@@ -343,9 +698,49 @@ func (b *ReturnStmt) Accept(visitor StmtVisitor) (err error) {
 	return visitor.VisitReturnStmt(b)
 }
 
+func (b *ReturnStmt) Span() token.Span {
+	return b.Range
+}
+
+type ThrowStmt struct {
+	Keyword *token.Token
+	Value   Expression
+	Range   token.Span
+}
+
+var _ Statement = (*ThrowStmt)(nil)
+
+func (b *ThrowStmt) Accept(visitor StmtVisitor) (err error) {
+	return visitor.VisitThrowStmt(b)
+}
+
+func (b *ThrowStmt) Span() token.Span {
+	return b.Range
+}
+
+// CatchName is nil when there is no catch clause, and FinallyBody is empty when there is no finally clause.
+type TryStmt struct {
+	Body        []Statement
+	CatchName   *token.Token
+	CatchBody   []Statement
+	FinallyBody []Statement
+	Range       token.Span
+}
+
+var _ Statement = (*TryStmt)(nil)
+
+func (b *TryStmt) Accept(visitor StmtVisitor) (err error) {
+	return visitor.VisitTryStmt(b)
+}
+
+func (b *TryStmt) Span() token.Span {
+	return b.Range
+}
+
 type VarStmt struct {
 	Name        *token.Token
 	Initializer Expression
+	Range       token.Span
 }
 
 var _ Statement = (*VarStmt)(nil)
@@ -354,9 +749,16 @@ func (b *VarStmt) Accept(visitor StmtVisitor) (err error) {
 	return visitor.VisitVarStmt(b)
 }
 
+func (b *VarStmt) Span() token.Span {
+	return b.Range
+}
+
+// Increment is optional, it comes from a desugared for loop and runs after every iteration, even on continue.
 type WhileStmt struct {
 	Condition Expression
 	Body      Statement
+	Increment Expression
+	Range     token.Span
 }
 
 var _ Statement = (*WhileStmt)(nil)
@@ -364,3 +766,7 @@ var _ Statement = (*WhileStmt)(nil)
 func (b *WhileStmt) Accept(visitor StmtVisitor) (err error) {
 	return visitor.VisitWhileStmt(b)
 }
+
+func (b *WhileStmt) Span() token.Span {
+	return b.Range
+}
@@ -28,15 +28,19 @@ func main() {
 	)
 	defer func() {
 		if err != nil {
-			fmt.Println(err)
+			fmt.Fprintln(os.Stderr, err)
 			os.Exit(exitCode)
 		}
 	}()
 
 	version.SetSubName("cli")
 
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		os.Exit(runFmt(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
 	if len(os.Args) > 2 {
-		fmt.Println("Usage: bluelox [script]")
+		fmt.Println("Usage: bluelox [script]\n       bluelox fmt [-w] [-l] [-d] [files...]")
 		exitCode = 64
 		return
 	}
@@ -45,6 +49,8 @@ func main() {
 	defer stop()
 
 	runner := lox.NewLox(os.Stdout)
+	runner.SetFileReader(os.ReadFile)
+	runner.SetModuleReader(os.ReadFile)
 	if len(os.Args) == 1 {
 		err = runner.RunPrompt(ctx)
 		if err != nil {
@@ -55,10 +61,25 @@ func main() {
 		return
 	}
 
-	err = runner.RunFile(ctx, os.Args[1])
+	// the prompt reads lines itself, so only scripts read stdin
+	runner.ChangeStdinTo(os.Stdin)
+
+	path := os.Args[1]
+	script, err := os.ReadFile(path)
 	if err != nil {
-		err = fmt.Errorf("running script file: %w", err)
+		err = fmt.Errorf("reading script file: %w", err)
+		exitCode = 65
+		return
+	}
 
+	err = runner.SetScriptPath(path)
+	if err != nil {
+		exitCode = 65
+		return
+	}
+
+	err = runner.Run(ctx, script)
+	if err != nil {
 		var runtimeErr *interpreter.RuntimeError
 		if errors.As(err, &runtimeErr) {
 			exitCode = 70
@@ -66,6 +87,8 @@ func main() {
 			exitCode = 65
 		}
 
-		return
+		_ = runner.RenderError(os.Stderr, path, script, err)
+		stop()
+		os.Exit(exitCode)
 	}
 }
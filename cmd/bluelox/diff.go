@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const diffContextLines = 3
+
+type diffOpKind int
+
+const (
+	diffEqual diffOpKind = iota
+	diffDelete
+	diffInsert
+)
+
+type diffOp struct {
+	kind diffOpKind
+	line string
+}
+
+// unifiedDiff returns the difference between a and b in unified format.
+//
+// It's based on the longest common subsequence of lines,
+// which is quadratic but fine for scripts of humane sizes.
+func unifiedDiff(filename string, a, b []byte) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	_, _ = fmt.Fprintf(&out, "--- %s.orig\n+++ %s\n", filename, filename)
+
+	// line numbers before each op, 0-based
+	aLines := make([]int, len(ops)+1)
+	bLines := make([]int, len(ops)+1)
+	for index, op := range ops {
+		aLines[index+1] = aLines[index]
+		bLines[index+1] = bLines[index]
+		if op.kind != diffInsert {
+			aLines[index+1]++
+		}
+		if op.kind != diffDelete {
+			bLines[index+1]++
+		}
+	}
+
+	for start := 0; start < len(ops); {
+		if ops[start].kind == diffEqual {
+			start++
+			continue
+		}
+
+		// extend the hunk while changes are close enough
+		end := start
+		for cursor := start; cursor < len(ops); cursor++ {
+			if ops[cursor].kind != diffEqual {
+				end = cursor + 1
+				continue
+			}
+			if cursor-end >= 2*diffContextLines {
+				break
+			}
+		}
+
+		hunkStart := start - diffContextLines
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		hunkEnd := end + diffContextLines
+		if hunkEnd > len(ops) {
+			hunkEnd = len(ops)
+		}
+
+		_, _ = fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(aLines[hunkStart], aLines[hunkEnd]-aLines[hunkStart]),
+			hunkRange(bLines[hunkStart], bLines[hunkEnd]-bLines[hunkStart]),
+		)
+		for _, op := range ops[hunkStart:hunkEnd] {
+			switch op.kind {
+			case diffEqual:
+				out.WriteByte(' ')
+			case diffDelete:
+				out.WriteByte('-')
+			case diffInsert:
+				out.WriteByte('+')
+			}
+			out.WriteString(op.line)
+			out.WriteByte('\n')
+		}
+
+		start = hunkEnd
+	}
+
+	return out.String()
+}
+
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+func splitLines(content []byte) (lines []string) {
+	if len(content) == 0 {
+		return nil
+	}
+
+	noEOL := !bytes.HasSuffix(content, []byte{'\n'})
+	lines = strings.Split(string(bytes.TrimSuffix(content, []byte{'\n'})), "\n")
+	if noEOL {
+		lines[len(lines)-1] += "\n\\ No newline at end of file"
+	}
+
+	return
+}
+
+func diffLines(a, b []string) (ops []diffOp) {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{kind: diffEqual, line: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{kind: diffDelete, line: a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: diffInsert, line: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{kind: diffDelete, line: a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{kind: diffInsert, line: b[j]})
+	}
+
+	return
+}
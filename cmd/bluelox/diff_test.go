@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_unifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "same",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "--- f.orig\n+++ f\n",
+		},
+		{
+			name: "changed line",
+			a:    "a\nb\nc\n",
+			b:    "a\nB\nc\n",
+			want: "--- f.orig\n+++ f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "a\n",
+			want: "--- f.orig\n+++ f\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			name: "to empty",
+			a:    "a\nb\n",
+			b:    "",
+			want: "--- f.orig\n+++ f\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "no newline at end of file",
+			a:    "a",
+			b:    "a\n",
+			want: "--- f.orig\n+++ f\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+a\n",
+		},
+		{
+			name: "context is limited",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n",
+			b:    "0\n2\n3\n4\n5\n6\n7\n8\n",
+			want: "--- f.orig\n+++ f\n@@ -1,4 +1,4 @@\n-1\n+0\n 2\n 3\n 4\n",
+		},
+		{
+			name: "far changes make separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:    "0\n2\n3\n4\n5\n6\n7\n8\n9\n11\n",
+			want: "--- f.orig\n+++ f\n@@ -1,4 +1,4 @@\n-1\n+0\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+11\n",
+		},
+		{
+			name: "near changes share a hunk",
+			a:    "1\n2\n3\n4\n5\n6\n",
+			b:    "0\n2\n3\n4\n5\n0\n",
+			want: "--- f.orig\n+++ f\n@@ -1,6 +1,6 @@\n-1\n+0\n 2\n 3\n 4\n 5\n-6\n+0\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, unifiedDiff("f", []byte(tt.a), []byte(tt.b)))
+		})
+	}
+}
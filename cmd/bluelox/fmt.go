@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nanmu42/bluelox/format"
+)
+
+const fmtUsage = `Usage: bluelox fmt [-w] [-l] [-d] [files...]
+
+Without files, bluelox fmt formats standard input.
+With -l or -d but not -w, it exits with status 1 when any input is not formatted.
+`
+
+// fmtFlags are options of bluelox fmt.
+type fmtFlags struct {
+	write, list, diff bool
+}
+
+// check tells whether the run checks formatting instead of producing it.
+func (f fmtFlags) check() bool {
+	return (f.list || f.diff) && !f.write
+}
+
+// runFmt mirrors gofmt, formatting is done by package format,
+// which is shared with the playground.
+//
+// Errors are written to stderr, and files after a bad one are still processed.
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) (exitCode int) {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(stderr, fmtUsage)
+		flags.PrintDefaults()
+	}
+	var options fmtFlags
+	flags.BoolVar(&options.write, "w", false, "write result to (source) file instead of stdout")
+	flags.BoolVar(&options.list, "l", false, "list files whose formatting differs from bluelox fmt's")
+	flags.BoolVar(&options.diff, "d", false, "display diffs instead of rewriting files")
+	err := flags.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 64
+	}
+
+	// the first failure decides the exit code
+	fail := func(code int, err error) {
+		_, _ = fmt.Fprintf(stderr, "bluelox fmt: %s\n", err)
+		if exitCode == 0 || exitCode == 1 {
+			exitCode = code
+		}
+	}
+	unformatted := func() {
+		if options.check() && exitCode == 0 {
+			exitCode = 1
+		}
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		if options.write {
+			fail(64, errors.New("can not use -w with standard input"))
+			return
+		}
+
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fail(74, fmt.Errorf("reading standard input: %w", err))
+			return
+		}
+
+		formatted, err := processSource("<standard input>", src, options, stdout)
+		if err != nil {
+			fail(65, err)
+			return
+		}
+		if !formatted {
+			unformatted()
+		}
+
+		return
+	}
+
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fail(66, fmt.Errorf("reading file: %w", err))
+			continue
+		}
+
+		formatted, err := processSource(file, src, options, stdout)
+		if err != nil {
+			fail(65, err)
+			continue
+		}
+		if !formatted {
+			unformatted()
+		}
+	}
+
+	return
+}
+
+// processSource formats one source and reports whether it has been formatted before.
+//
+// Nothing is written if formatting fails, format.Source makes sure
+// the result is the same program as src.
+func processSource(filename string, src []byte, options fmtFlags, stdout io.Writer) (formatted bool, err error) {
+	result, err := format.Source(src)
+	if err != nil {
+		err = fmt.Errorf("formatting %s: %w", filename, err)
+		return
+	}
+
+	formatted = bytes.Equal(src, result)
+
+	if !formatted {
+		if options.list {
+			_, _ = fmt.Fprintln(stdout, filename)
+		}
+		if options.write {
+			err = writeFile(filename, result)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", filename, err)
+				return
+			}
+		}
+		if options.diff {
+			_, _ = io.WriteString(stdout, unifiedDiff(filename, src, result))
+		}
+	}
+
+	if !options.list && !options.write && !options.diff {
+		_, err = stdout.Write(result)
+		if err != nil {
+			err = fmt.Errorf("writing to stdout: %w", err)
+			return
+		}
+	}
+
+	return
+}
+
+func writeFile(filename string, content []byte) (err error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		err = fmt.Errorf("stating file: %w", err)
+		return
+	}
+
+	err = os.WriteFile(filename, content, info.Mode().Perm())
+	if err != nil {
+		err = fmt.Errorf("writing file: %w", err)
+		return
+	}
+
+	return
+}
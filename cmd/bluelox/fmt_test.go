@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_runFmt(t *testing.T) {
+	const (
+		formatted   = "var a = 1;\n"
+		unformatted = "var a=1;\n"
+		broken      = "var a=;\n"
+	)
+
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+		// files are written into a temporary directory, and args refer to them by name
+		files        map[string]string
+		wantExitCode int
+		wantStdout   string
+		wantStderr   string
+		wantFiles    map[string]string
+	}{
+		{
+			name:       "stdin formatted",
+			stdin:      formatted,
+			wantStdout: formatted,
+		},
+		{
+			name:       "stdin not formatted",
+			stdin:      unformatted,
+			wantStdout: formatted,
+		},
+		{
+			name:         "stdin list",
+			args:         []string{"-l"},
+			stdin:        unformatted,
+			wantExitCode: 1,
+			wantStdout:   "<standard input>\n",
+		},
+		{
+			name:  "stdin list formatted",
+			args:  []string{"-l"},
+			stdin: formatted,
+		},
+		{
+			name:         "stdin broken",
+			stdin:        broken,
+			wantExitCode: 65,
+			wantStderr:   "bluelox fmt: formatting <standard input>: parsing error: 1:7: parsing primary: unexpected token Semicolon \";\"\n",
+		},
+		{
+			name:         "stdin write",
+			args:         []string{"-w"},
+			stdin:        unformatted,
+			wantExitCode: 64,
+			wantStderr:   "bluelox fmt: can not use -w with standard input\n",
+		},
+		{
+			name:       "files printed",
+			args:       []string{"a.lox", "b.lox"},
+			files:      map[string]string{"a.lox": unformatted, "b.lox": formatted},
+			wantStdout: formatted + formatted,
+		},
+		{
+			name:         "files listed",
+			args:         []string{"-l", "a.lox", "b.lox"},
+			files:        map[string]string{"a.lox": unformatted, "b.lox": formatted},
+			wantExitCode: 1,
+			wantStdout:   "a.lox\n",
+		},
+		{
+			name:         "files diffed",
+			args:         []string{"-d", "a.lox"},
+			files:        map[string]string{"a.lox": unformatted},
+			wantExitCode: 1,
+			wantStdout:   "--- a.lox.orig\n+++ a.lox\n@@ -1 +1 @@\n-var a=1;\n+var a = 1;\n",
+		},
+		{
+			name:       "files written and listed",
+			args:       []string{"-w", "-l", "a.lox", "b.lox"},
+			files:      map[string]string{"a.lox": unformatted, "b.lox": formatted},
+			wantStdout: "a.lox\n",
+			wantFiles:  map[string]string{"a.lox": formatted, "b.lox": formatted},
+		},
+		{
+			name:  "files with comments before else written",
+			args:  []string{"-w", "if.lox"},
+			files: map[string]string{"if.lox": "if (a) { print 1; } // after if\nelse { print 2; }\n"},
+			wantFiles: map[string]string{
+				"if.lox": "if (a) {\n\tprint 1;\n} // after if\nelse {\n\tprint 2;\n}\n",
+			},
+		},
+		{
+			name:         "bad files do not stop the rest",
+			args:         []string{"-w", "missing.lox", "broken.lox", "a.lox"},
+			files:        map[string]string{"broken.lox": broken, "a.lox": unformatted},
+			wantExitCode: 66,
+			wantStderr: "bluelox fmt: reading file: open missing.lox: no such file or directory\n" +
+				"bluelox fmt: formatting broken.lox: parsing error: 1:7: parsing primary: unexpected token Semicolon \";\"\n",
+			wantFiles: map[string]string{"broken.lox": broken, "a.lox": formatted},
+		},
+		{
+			name:         "bad flag",
+			args:         []string{"-x"},
+			wantExitCode: 64,
+			wantStderr: "flag provided but not defined: -x\n" + fmtUsage +
+				"  -d\tdisplay diffs instead of rewriting files\n" +
+				"  -l\tlist files whose formatting differs from bluelox fmt's\n" +
+				"  -w\twrite result to (source) file instead of stdout\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+			}
+			wd, err := os.Getwd()
+			require.NoError(t, err)
+			require.NoError(t, os.Chdir(dir))
+			defer func() {
+				require.NoError(t, os.Chdir(wd))
+			}()
+
+			var stdout, stderr strings.Builder
+			exitCode := runFmt(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			require.Equal(t, tt.wantExitCode, exitCode)
+			require.Equal(t, tt.wantStdout, stdout.String())
+			require.Equal(t, tt.wantStderr, stderr.String())
+
+			for name, want := range tt.wantFiles {
+				got, err := os.ReadFile(name)
+				require.NoError(t, err)
+				require.Equal(t, want, string(got))
+			}
+		})
+	}
+}
@@ -21,8 +21,8 @@ const (
 var exprTypes = Types{
 	{
 		Name:    "AssignExpr",
-		Fields:  "Name *token.Token, Value Expression",
-		Comment: "",
+		Fields:  "Name *token.Token, Operator *token.Token, Value Expression",
+		Comment: "Operator is nil for \"=\", or one of compound assignments like \"+=\".",
 	},
 	{
 		Name:    `BinaryExpr`,
@@ -34,6 +34,16 @@ var exprTypes = Types{
 		Fields:  `Callee Expression, Paren *token.Token, Arguments []Expression`,
 		Comment: "",
 	},
+	{
+		Name:    "ConditionalExpr",
+		Fields:  "Condition Expression, ThenBranch Expression, ElseBranch Expression",
+		Comment: "ConditionalExpr is the ternary operator \"?:\".",
+	},
+	{
+		Name:    "FunctionExpr",
+		Fields:  "Keyword *token.Token, Params []*token.Token, Body []Statement",
+		Comment: "FunctionExpr is an anonymous function.",
+	},
 	{
 		Name:    `GetExpr`,
 		Fields:  `Object Expression, Name *token.Token`,
@@ -44,6 +54,26 @@ var exprTypes = Types{
 		Fields:  `Expr Expression`,
 		Comment: "",
 	},
+	{
+		Name:    "IndexGetExpr",
+		Fields:  "Object Expression, Bracket *token.Token, Index Expression",
+		Comment: "",
+	},
+	{
+		Name:    "IndexSetExpr",
+		Fields:  "Object Expression, Bracket *token.Token, Index Expression, Operator *token.Token, Value Expression",
+		Comment: "Operator is nil for \"=\", or one of compound assignments like \"+=\".",
+	},
+	{
+		Name:    "InterpolationExpr",
+		Fields:  "Parts []Expression",
+		Comment: "InterpolationExpr is a string with embedded expressions, Parts are joined after stringified.",
+	},
+	{
+		Name:    "ListExpr",
+		Fields:  "Elements []Expression",
+		Comment: "",
+	},
 	{
 		Name:    `LiteralExpr`,
 		Fields:  `Value interface{}`,
@@ -54,10 +84,15 @@ var exprTypes = Types{
 		Fields:  "Left Expression, Operator *token.Token, Right Expression",
 		Comment: "",
 	},
+	{
+		Name:    "MapExpr",
+		Fields:  "Brace *token.Token, Keys []Expression, Values []Expression",
+		Comment: "MapExpr is a map literal, Keys and Values are paired by index.",
+	},
 	{
 		Name:    "SetExpr",
-		Fields:  "Object Expression, Name *token.Token, Value Expression",
-		Comment: "",
+		Fields:  "Object Expression, Name *token.Token, Operator *token.Token, Value Expression",
+		Comment: "Operator is nil for \"=\", or one of compound assignments like \"+=\".",
 	},
 	{
 		Name:    "SuperExpr",
@@ -87,11 +122,26 @@ var stmtTypes = Types{
 		Fields:  "Stmts []Statement",
 		Comment: "",
 	},
+	{
+		Name:    "BreakStmt",
+		Fields:  "Keyword *token.Token",
+		Comment: "",
+	},
 	{
 		Name:    "ClassStmt",
-		Fields:  "Name *token.Token, SuperClass *VariableExpr, Methods []*FunctionStmt",
+		Fields:  "Name *token.Token, SuperClass *VariableExpr, Methods []*FunctionStmt, StaticMethods []*FunctionStmt",
+		Comment: "StaticMethods are declared with \"class\" in the class body, and are called on the class itself.",
+	},
+	{
+		Name:    "ContinueStmt",
+		Fields:  "Keyword *token.Token",
 		Comment: "",
 	},
+	{
+		Name:    "ExportStmt",
+		Fields:  "Keyword *token.Token, Decl Statement",
+		Comment: "ExportStmt makes the class, function or variable declared by Decl visible to importers.",
+	},
 	{
 		Name:    "ExprStmt",
 		Fields:  "Expr Expression",
@@ -99,14 +149,19 @@ var stmtTypes = Types{
 	},
 	{
 		Name:    "FunctionStmt",
-		Fields:  "Name *token.Token, Params []*token.Token, Body []Statement",
-		Comment: "",
+		Fields:  "Name *token.Token, Params []*token.Token, Body []Statement, IsGetter bool",
+		Comment: "IsGetter is set for methods declared without a parameter list, which run when the property is accessed.",
 	},
 	{
 		Name:    "IfStmt",
 		Fields:  "Condition Expression, ThenBranch Statement, ElseBranch Statement",
 		Comment: "",
 	},
+	{
+		Name:    "ImportStmt",
+		Fields:  "Keyword *token.Token, Names []*token.Token, Path *token.Token",
+		Comment: "ImportStmt imports Names exported by the module at Path, or all of them if Names is nil.",
+	},
 	{
 		Name:    "PrintStmt",
 		Fields:  "Expr Expression",
@@ -117,6 +172,16 @@ var stmtTypes = Types{
 		Fields:  "Keyword *token.Token, Value Expression",
 		Comment: "",
 	},
+	{
+		Name:    "ThrowStmt",
+		Fields:  "Keyword *token.Token, Value Expression",
+		Comment: "",
+	},
+	{
+		Name:    "TryStmt",
+		Fields:  "Body []Statement, CatchName *token.Token, CatchBody []Statement, FinallyBody []Statement",
+		Comment: "CatchName is nil when there is no catch clause, and FinallyBody is empty when there is no finally clause.",
+	},
 	{
 		Name:    "VarStmt",
 		Fields:  "Name *token.Token, Initializer Expression",
@@ -124,8 +189,8 @@ var stmtTypes = Types{
 	},
 	{
 		Name:    "WhileStmt",
-		Fields:  "Condition Expression, Body Statement",
-		Comment: "",
+		Fields:  "Condition Expression, Body Statement, Increment Expression",
+		Comment: "Increment is optional, it comes from a desugared for loop and runs after every iteration, even on continue.",
 	},
 }
 
@@ -220,10 +285,14 @@ import (
 
 type Expression interface {
 	Accept(visitor ExprVisitor) (result interface{}, err error)
+	// Span returns where the expression locates in source.
+	Span() token.Span
 }
 
 type Statement interface {
 	Accept(visitor StmtVisitor) (err error)
+	// Span returns where the statement locates in source.
+	Span() token.Span
 }
 
 `)
@@ -365,6 +434,9 @@ func (g *Generator) writeType(kind string, item Type) {
 
 	fields := strings.Split(item.Fields, ",")
 	g.buf.WriteString(strings.Join(fields, "\n"))
+	g.linebreak()
+	g.buf.WriteString("Range token.Span")
+	g.linebreak()
 
 	g.buf.WriteString(`}`)
 	g.linebreak()
@@ -390,4 +462,11 @@ func (g *Generator) writeType(kind string, item Type) {
 
 	g.linebreak()
 	g.linebreak()
+
+	_, _ = fmt.Fprintf(&g.buf, `func (b *%s) Span() token.Span {
+	return b.Range
+}`, item.Name)
+
+	g.linebreak()
+	g.linebreak()
 }
@@ -6,14 +6,21 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"runtime/debug"
+	"strings"
 	"sync"
 	"syscall/js"
 
+	"github.com/nanmu42/bluelox/diag"
+	"github.com/nanmu42/bluelox/format"
 	"github.com/nanmu42/bluelox/lox"
 )
 
 var noopWriter = NoopWriter{}
 
+// scriptName is how errors refer to the script in playground
+const scriptName = "main.lox"
+
 type Runner struct {
 	// protect status
 	mu sync.Mutex
@@ -22,10 +29,6 @@ type Runner struct {
 	running   bool
 	cancelRun context.CancelFunc
 	lox       *lox.Lox
-
-	// TODO: implement us
-	formatting bool
-	cancelFmt  context.CancelFunc
 }
 
 func (r *Runner) Run(this js.Value, args []js.Value) (err error) {
@@ -49,10 +52,6 @@ func (r *Runner) Run(this js.Value, args []js.Value) (err error) {
 		err = errors.New("stopping running program, wait for 'Program exited' and click Run to try again. Press F5 to refresh if it takes too long")
 		return
 	}
-	if r.formatting {
-		err = errors.New("stopping formatting program, please try again")
-		return
-	}
 
 	stdout := newOutputWriter()
 	r.lox = lox.NewLox(stdout)
@@ -71,14 +70,24 @@ func (r *Runner) Run(this js.Value, args []js.Value) (err error) {
 	r.mu.Unlock()
 	lockReleased = true
 
-	err = r.lox.Run(ctx, []byte(script.String()))
+	defer func() {
+		// keep the playground alive, the interpreter must have a bug
+		if reason := recover(); reason != nil {
+			err = fmt.Errorf("loxrun: interpreter panicked: %v\n%s", reason, debug.Stack())
+		}
+	}()
+
+	source := []byte(script.String())
+	err = r.lox.Run(ctx, source)
 	if errors.Is(err, context.Canceled) {
 		// user cancel is ok
 		err = nil
 		return
 	}
 	if err != nil {
-		return fmt.Errorf("loxrun: %w", err)
+		var b strings.Builder
+		_ = r.lox.RenderError(&b, scriptName, source, err)
+		return errors.New(strings.TrimSuffix(b.String(), "\n"))
 	}
 
 	return nil
@@ -92,16 +101,29 @@ func (r *Runner) Stop(this js.Value, args []js.Value) (err error) {
 		r.lox.ChangeStdoutTo(noopWriter)
 		r.cancelRun()
 	}
-	if r.formatting {
-		// TODO: deal with fmt
-	}
 
 	return nil
 }
 
-func (r *Runner) Fmt(this js.Value, args []js.Value) (err error) {
-	// TODO: implement me
-	return errors.New("loxfmt: not implemented yet")
+// Fmt formats the script and returns the result.
+func (r *Runner) Fmt(this js.Value, args []js.Value) (result interface{}, err error) {
+	if argLength := len(args); argLength != 1 {
+		return nil, fmt.Errorf("want 1 arg, got %d", argLength)
+	}
+	script := args[0]
+	if scriptType := script.Type(); scriptType != js.TypeString {
+		return nil, fmt.Errorf("want arg type %s, got %s", js.TypeString.String(), scriptType.String())
+	}
+
+	source := []byte(script.String())
+	formatted, err := format.Source(source)
+	if err != nil {
+		var b strings.Builder
+		_ = diag.Render(&b, scriptName, source, diag.Collect(err, diag.PhaseParse))
+		return nil, errors.New(strings.TrimSuffix(b.String(), "\n"))
+	}
+
+	return string(formatted), nil
 }
 
 type OutputWriter struct {
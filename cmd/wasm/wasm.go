@@ -22,7 +22,7 @@ func main() {
 	runner := &Runner{}
 
 	js.Global().Set("loxrun", asyncFuncOf(runner.Run))
-	js.Global().Set("loxfmt", asyncFuncOf(runner.Fmt))
+	js.Global().Set("loxfmt", asyncResultFuncOf(runner.Fmt))
 	js.Global().Set("loxstop", asyncFuncOf(runner.Stop))
 	js.Global().Set("loxversion", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		return version.FullNameWithBuildDate
@@ -35,19 +35,27 @@ func main() {
 //
 // source: https://github.com/golang/go/issues/41310#issuecomment-725809881
 func asyncFuncOf(fn func(this js.Value, args []js.Value) error) js.Func {
+	return asyncResultFuncOf(func(this js.Value, args []js.Value) (interface{}, error) {
+		return nil, fn(this, args)
+	})
+}
+
+// asyncResultFuncOf is like asyncFuncOf,
+// and the promise resolves with what fn returns.
+func asyncResultFuncOf(fn func(this js.Value, args []js.Value) (interface{}, error)) js.Func {
 	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		handler := js.FuncOf(func(_ js.Value, promise []js.Value) interface{} {
 			resolve := promise[0]
 			reject := promise[1]
 
 			go func() {
-				err := fn(this, args)
+				result, err := fn(this, args)
 				if err != nil {
 					reject.Invoke(err.Error())
 					return
 				}
 
-				resolve.Invoke()
+				resolve.Invoke(result)
 			}()
 
 			return nil
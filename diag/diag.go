@@ -0,0 +1,223 @@
+// Package diag defines structured diagnostics shared by
+// the scanner, parser, resolver and interpreter.
+package diag
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nanmu42/bluelox/token"
+)
+
+// Severity tells how bad a diagnostic is.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+
+	return "Severity(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Phase is the stage where a diagnostic is reported.
+type Phase int
+
+const (
+	PhaseScan Phase = iota
+	PhaseParse
+	PhaseResolve
+	PhaseRuntime
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseScan:
+		return "scan"
+	case PhaseParse:
+		return "parse"
+	case PhaseResolve:
+		return "resolve"
+	case PhaseRuntime:
+		return "runtime"
+	}
+
+	return "Phase(" + strconv.Itoa(int(p)) + ")"
+}
+
+// Code identifies the kind of a diagnostic,
+// tools can rely on it instead of the message.
+type Code string
+
+const (
+	CodeUnknown Code = "unknown"
+
+	// scanning
+	CodeUnexpectedCharacter Code = "unexpected-character"
+	CodeInvalidEscape       Code = "invalid-escape"
+	CodeUnterminatedString  Code = "unterminated-string"
+	CodeInvalidNumber       Code = "invalid-number"
+
+	// parsing
+	CodeUnexpectedToken         Code = "unexpected-token"
+	CodeTooManyArguments        Code = "too-many-arguments"
+	CodeInvalidAssignmentTarget Code = "invalid-assignment-target"
+
+	// resolving
+	CodeDuplicateDeclaration   Code = "duplicate-declaration"
+	CodeReadInOwnInitializer   Code = "read-in-own-initializer"
+	CodeTopLevelReturn         Code = "top-level-return"
+	CodeReturnFromInitializer  Code = "return-from-initializer"
+	CodeThisOutsideClass       Code = "this-outside-class"
+	CodeSuperOutsideClass      Code = "super-outside-class"
+	CodeSuperWithoutSuperclass Code = "super-without-superclass"
+	CodeSelfInheritance        Code = "self-inheritance"
+	CodeOutsideLoop            Code = "outside-loop"
+	CodeGetterInitializer      Code = "getter-initializer"
+	CodeNotTopLevel            Code = "not-top-level"
+
+	// runtime
+	CodeRuntime           Code = "runtime"
+	CodeOperandType       Code = "operand-type"
+	CodeDivisionByZero    Code = "division-by-zero"
+	CodeUndefinedVariable Code = "undefined-variable"
+	CodeUndefinedProperty Code = "undefined-property"
+	CodeNotCallable       Code = "not-callable"
+	CodeArityMismatch     Code = "arity-mismatch"
+	CodeNotInstance       Code = "not-instance"
+	CodeNotClass          Code = "not-class"
+	CodeInvalidIndex      Code = "invalid-index"
+	CodeIndexOutOfRange   Code = "index-out-of-range"
+	CodeKeyNotFound       Code = "key-not-found"
+	CodeUncaughtException Code = "uncaught-exception"
+	CodeImport            Code = "import"
+	CodeImportCycle       Code = "import-cycle"
+	CodeNotExported       Code = "not-exported"
+	CodeStepLimit         Code = "step-limit"
+	CodeCallDepthLimit    Code = "call-depth-limit"
+	CodeMemoryLimit       Code = "memory-limit"
+)
+
+// Note is extra information attached to a diagnostic.
+type Note struct {
+	Message string
+	Span    token.Span
+}
+
+// Diagnostic is a problem found in a Lox program.
+type Diagnostic struct {
+	Severity Severity
+	Phase    Phase
+	Code     Code
+	Message  string
+	// Span where the problem locates, zero value if unknown
+	Span token.Span
+	// Notes related notes, optional
+	Notes []Note
+}
+
+// HasSpan reports whether the diagnostic knows where the problem is.
+func (d *Diagnostic) HasSpan() bool {
+	return d.Span.Line > 0
+}
+
+func (d *Diagnostic) Error() string {
+	if d.HasSpan() {
+		return d.Span.String() + ": " + d.Message
+	}
+
+	return d.Message
+}
+
+// Errorf returns an error diagnostic with formatted message.
+func Errorf(phase Phase, code Code, span token.Span, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{
+		Severity: SeverityError,
+		Phase:    phase,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+		Span:     span,
+	}
+}
+
+// FromError converts err into a diagnostic.
+//
+// If err wraps a diagnostic, its severity, span, code and notes are kept,
+// and the message includes the context added by wrapping.
+func FromError(phase Phase, err error) *Diagnostic {
+	var wrapped *Diagnostic
+	if !errors.As(err, &wrapped) {
+		return &Diagnostic{
+			Severity: SeverityError,
+			Phase:    phase,
+			Code:     CodeUnknown,
+			Message:  err.Error(),
+		}
+	}
+
+	d := *wrapped
+	// the wrapped diagnostic reads as "line:column: message" in err
+	d.Message = strings.TrimSuffix(err.Error(), wrapped.Error()) + wrapped.Message
+	return &d
+}
+
+// List is a list of diagnostics, in the order they are found.
+type List []*Diagnostic
+
+func (l List) Error() string {
+	switch len(l) {
+	case 0:
+		return "no error"
+	case 1:
+		return l[0].Error()
+	}
+
+	return fmt.Sprintf("%s (and %d more error(s))", l[0].Error(), len(l)-1)
+}
+
+// Err returns an error equivalent to this list,
+// which is nil if the list is empty.
+func (l List) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+
+	return l
+}
+
+// Diagnostics implements Diagnoser.
+func (l List) Diagnostics() List {
+	return l
+}
+
+// Diagnoser is implemented by errors that are made of diagnostics.
+type Diagnoser interface {
+	Diagnostics() List
+}
+
+// Collect returns the diagnostics that err is made of.
+//
+// Errors that know nothing about diagnostics are converted
+// with FromError and fallback phase.
+func Collect(err error, fallback Phase) List {
+	if err == nil {
+		return nil
+	}
+
+	var diagnoser Diagnoser
+	if errors.As(err, &diagnoser) {
+		return diagnoser.Diagnostics()
+	}
+
+	return List{FromError(fallback, err)}
+}
@@ -0,0 +1,49 @@
+package diag
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nanmu42/bluelox/token"
+)
+
+func TestFromError(t *testing.T) {
+	span := token.Span{Offset: 4, End: 5, Line: 1, Column: 5}
+	root := Errorf(PhaseParse, CodeUnexpectedToken, span, "want token type %s, got %s", token.Semicolon, token.EOF)
+
+	got := FromError(PhaseParse, fmt.Errorf("expected ';' after value: %w", root))
+	require.Equal(t, &Diagnostic{
+		Severity: SeverityError,
+		Phase:    PhaseParse,
+		Code:     CodeUnexpectedToken,
+		Message:  "expected ';' after value: want token type Semicolon, got EOF",
+		Span:     span,
+	}, got)
+	require.Equal(t, "1:5: expected ';' after value: want token type Semicolon, got EOF", got.Error())
+
+	got = FromError(PhaseRuntime, errors.New("something bad"))
+	require.Equal(t, CodeUnknown, got.Code)
+	require.Equal(t, PhaseRuntime, got.Phase)
+	require.False(t, got.HasSpan())
+	require.Equal(t, "something bad", got.Error())
+}
+
+func TestCollect(t *testing.T) {
+	require.Nil(t, Collect(nil, PhaseRuntime))
+	require.NoError(t, List(nil).Err())
+
+	list := List{
+		Errorf(PhaseScan, CodeUnexpectedCharacter, token.Span{Line: 1, Column: 1}, "unexpected character %q", '@'),
+		Errorf(PhaseScan, CodeUnterminatedString, token.Span{Line: 2, Column: 3}, "unterminated string"),
+	}
+	err := fmt.Errorf("scaning tokens: %w", list.Err())
+	require.Equal(t, list, Collect(err, PhaseRuntime))
+	require.Equal(t, "scaning tokens: 1:1: unexpected character '@' (and 1 more error(s))", err.Error())
+
+	got := Collect(errors.New("plain"), PhaseRuntime)
+	require.Len(t, got, 1)
+	require.Equal(t, PhaseRuntime, got[0].Phase)
+}
@@ -0,0 +1,107 @@
+package diag
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nanmu42/bluelox/token"
+)
+
+// Render writes diags in a human friendly way, like:
+//
+//	main.lox:3:9: error: division by zero
+//	  |
+//	3 | print a / 0;
+//	  |         ^
+//
+// filename is used as is, source is the script where diags are found.
+// Diagnostics without a span are written as a single line.
+func Render(w io.Writer, filename string, source []byte, diags List) (err error) {
+	return RenderSources(w, filename, source, diags, nil)
+}
+
+// Sources returns the source of the module at path, which is token.Span.File,
+// ok is false if it's unknown.
+type Sources func(path string) (source []byte, ok bool)
+
+// RenderSources is Render for programs made of modules,
+// diagnostics in modules are written with their paths and excerpts of their sources.
+//
+// sources can be nil, and excerpts are left out for unknown modules.
+func RenderSources(w io.Writer, filename string, source []byte, diags List, sources Sources) (err error) {
+	var b strings.Builder
+
+	// where span locates
+	sourceOf := func(span token.Span) (string, []byte) {
+		if span.File == "" {
+			return filename, source
+		}
+		if sources == nil {
+			return span.File, nil
+		}
+
+		moduleSource, _ := sources(span.File)
+		return span.File, moduleSource
+	}
+
+	for _, d := range diags {
+		name, src := sourceOf(d.Span)
+		renderOne(&b, name, src, d.Span, d.Severity.String(), d.Message)
+		for _, note := range d.Notes {
+			name, src = sourceOf(note.Span)
+			renderOne(&b, name, src, note.Span, "note", note.Message)
+		}
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return
+}
+
+func renderOne(b *strings.Builder, filename string, source []byte, span token.Span, label, message string) {
+	b.WriteString(filename)
+	if span.Line > 0 {
+		b.WriteString(":" + span.String())
+	}
+	_, _ = fmt.Fprintf(b, ": %s: %s\n", label, message)
+
+	if span.Line <= 0 || source == nil || span.Offset > len(source) {
+		return
+	}
+
+	lineStart := bytes.LastIndexByte(source[:span.Offset], '\n') + 1
+	lineEnd := len(source)
+	if index := bytes.IndexByte(source[span.Offset:], '\n'); index >= 0 {
+		lineEnd = span.Offset + index
+	}
+	line := strings.TrimSuffix(string(source[lineStart:lineEnd]), "\r")
+
+	number := strconv.Itoa(span.Line)
+	gutter := strings.Repeat(" ", len(number))
+	_, _ = fmt.Fprintf(b, "%s |\n", gutter)
+	_, _ = fmt.Fprintf(b, "%s | %s\n", number, line)
+
+	// keep tabs so that the caret lines up with the source line
+	var padding strings.Builder
+	for _, c := range string(source[lineStart:span.Offset]) {
+		if c == '\t' {
+			padding.WriteRune('\t')
+		} else {
+			padding.WriteRune(' ')
+		}
+	}
+
+	end := span.End
+	if end > lineEnd {
+		end = lineEnd
+	}
+	width := 1
+	if end > span.Offset {
+		width = utf8.RuneCount(source[span.Offset:end])
+	}
+
+	_, _ = fmt.Fprintf(b, "%s | %s^%s\n", gutter, padding.String(), strings.Repeat("~", width-1))
+}
@@ -0,0 +1,71 @@
+package diag
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nanmu42/bluelox/token"
+)
+
+func TestRender(t *testing.T) {
+	source := []byte("var a = 1;\n\tprint \"北京\" / a;\n")
+
+	tests := []struct {
+		name  string
+		diags List
+		want  string
+	}{
+		{
+			name: "caret",
+			diags: List{
+				Errorf(PhaseRuntime, CodeOperandType, token.Span{Offset: 27, End: 28, Line: 2, Column: 13}, "operands must be numbers"),
+			},
+			want: `main.lox:2:13: error: operands must be numbers
+  |
+2 | 	print "北京" / a;
+  | 	           ^
+`,
+		},
+		{
+			name: "underline and note",
+			diags: List{
+				{
+					Severity: SeverityError,
+					Phase:    PhaseResolve,
+					Code:     CodeDuplicateDeclaration,
+					Message:  "variable \"a\" already existed in this scope",
+					Span:     token.Span{Offset: 18, End: 26, Line: 2, Column: 8},
+					Notes: []Note{
+						{Message: "previously declared here", Span: token.Span{Offset: 4, End: 5, Line: 1, Column: 5}},
+					},
+				},
+			},
+			want: `main.lox:2:8: error: variable "a" already existed in this scope
+  |
+2 | 	print "北京" / a;
+  | 	      ^~~~
+main.lox:1:5: note: previously declared here
+  |
+1 | var a = 1;
+  |     ^
+`,
+		},
+		{
+			name: "no span",
+			diags: List{
+				Errorf(PhaseRuntime, CodeUnknown, token.Span{}, "something bad"),
+			},
+			want: "main.lox: error: something bad\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			err := Render(&b, "main.lox", source, tt.diags)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, b.String())
+		})
+	}
+}
@@ -0,0 +1,449 @@
+// Package format implements canonical formatting of Lox source code.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/nanmu42/bluelox/parser"
+	"github.com/nanmu42/bluelox/scanner"
+	"github.com/nanmu42/bluelox/token"
+)
+
+// Source formats Lox source in canonical style:
+// one statement per line, tab indentation, one space around binary operators,
+// and at most one blank line between statements. Comments are kept.
+//
+// src must be a syntactically valid Lox program,
+// otherwise the scanning or parsing error is returned.
+// An error is returned as well if the result is not the same program as src,
+// which is a bug of the formatter.
+func Source(src []byte) (formatted []byte, err error) {
+	s := scanner.NewScanner(src)
+	tokens, err := s.ScanTokens()
+	if err != nil {
+		err = fmt.Errorf("scaning tokens: %w", err)
+		return
+	}
+
+	// formatting a broken program does more harm than good.
+	_, err = parser.NewParser(tokens).Parse()
+	if err != nil {
+		return
+	}
+
+	p := newPrinter(tokens, s.Comments())
+	formatted = p.print()
+
+	// never change what the program means
+	err = sameTokens(tokens, formatted)
+	if err != nil {
+		formatted = nil
+		err = fmt.Errorf("formatting changes the program, which is a bug: %w", err)
+		return
+	}
+
+	return
+}
+
+// sameTokens checks whether formatted is made of tokens the same as want,
+// comments aside.
+func sameTokens(want []*token.Token, formatted []byte) (err error) {
+	got, err := scanner.NewScanner(formatted).ScanTokens()
+	if err != nil {
+		err = fmt.Errorf("scaning formatted source: %w", err)
+		return
+	}
+
+	// both end with EOF, so the shorter one is out at a mismatch
+	for index, tok := range want {
+		if got[index].Type != tok.Type || got[index].Lexeme != tok.Lexeme {
+			err = fmt.Errorf("%s %q at %s becomes %s %q", tok.Type, tok.Lexeme, tok.Span(), got[index].Type, got[index].Lexeme)
+			return
+		}
+	}
+
+	return
+}
+
+// printer lays out tokens one by one,
+// newlines are decided by the token stream instead of the original source,
+// except that blank lines between statements are kept.
+type printer struct {
+	tokens   []*token.Token
+	comments []*token.Token
+
+	buf bytes.Buffer
+
+	// index of the next token
+	current int
+	// index of the next comment
+	nextComment int
+
+	indent int
+	// enclosing parentheses, innermost last,
+	// true for the ones holding parameters of an anonymous function
+	parens []bool
+	// enclosing braces, innermost last
+	braces []brace
+	// how many brackets enclose the next token
+	brackets int
+	// nesting depth of each "?" waiting for its ":", innermost last
+	questions []int
+	// newlines wanted before the next output
+	pendingNewlines int
+	// the last token written, comments excluded
+	last *token.Token
+	// whether last is an unary operator
+	lastIsUnary bool
+	// whether last closes parameters of an anonymous function
+	lastClosesParams bool
+	// whether last closes braces in an expression,
+	// that is, the body of an anonymous function or a map literal
+	lastClosesExpr bool
+	// whether any comment is written after last
+	commentAfterLast bool
+	// source line where the last token or comment written ends
+	lastLine int
+}
+
+// brace is an opening brace being printed.
+type brace struct {
+	// whether it opens the body of an anonymous function
+	lambda bool
+	// whether it opens a map literal
+	literal bool
+	// whether it opens names of an import statement
+	names bool
+	// whether the map literal or the names hold comments,
+	// so that they take one entry per line instead of staying on one line
+	multiline bool
+	// how many parentheses and brackets enclose it
+	parenDepth, bracketDepth int
+}
+
+// inline reports whether b stays on one line.
+func (b brace) inline() bool {
+	return (b.literal || b.names) && !b.multiline
+}
+
+func newPrinter(tokens []*token.Token, comments []*token.Token) *printer {
+	return &printer{
+		tokens:   tokens,
+		comments: comments,
+	}
+}
+
+func (p *printer) print() []byte {
+	for ; p.current < len(p.tokens); p.current++ {
+		tok := p.tokens[p.current]
+
+		p.flushCommentsBefore(tok.Offset)
+		if tok.Type == token.EOF {
+			break
+		}
+
+		p.writeToken(tok)
+	}
+
+	// comments after the last token
+	p.flushCommentsBefore(-1)
+
+	if p.buf.Len() > 0 {
+		p.buf.WriteByte('\n')
+	}
+
+	return p.buf.Bytes()
+}
+
+// flushCommentsBefore writes comments ahead of offset,
+// offset < 0 means all the remaining comments.
+func (p *printer) flushCommentsBefore(offset int) {
+	for ; p.nextComment < len(p.comments); p.nextComment++ {
+		comment := p.comments[p.nextComment]
+		if offset >= 0 && comment.Offset >= offset {
+			return
+		}
+
+		if p.last != nil && comment.Line == p.lastLine && p.buf.Len() > 0 {
+			// trailing comment, stays on the line of the code it describes
+			p.buf.WriteByte(' ')
+			p.buf.WriteString(comment.Lexeme)
+			if p.pendingNewlines == 0 {
+				p.pendingNewlines = 1
+			}
+			p.commentAfterLast = true
+			continue
+		}
+
+		p.writeNewlines(comment.Line, false)
+		p.buf.WriteString(comment.Lexeme)
+		p.pendingNewlines = 1
+		p.lastLine = comment.Line
+		p.commentAfterLast = true
+	}
+}
+
+// writeNewlines flushes pending newlines along with indentation.
+// One blank line of the original source is kept unless noBlank is set.
+func (p *printer) writeNewlines(line int, noBlank bool) {
+	if p.buf.Len() == 0 {
+		p.pendingNewlines = 0
+		p.writeIndent()
+		return
+	}
+
+	newlines := p.pendingNewlines
+	if newlines == 0 {
+		newlines = 1
+	}
+	if newlines == 1 && !noBlank && line-p.lastLine > 1 &&
+		(p.last == nil || p.last.Type != token.LeftBrace || p.commentAfterLast) {
+		newlines = 2
+	}
+
+	for i := 0; i < newlines; i++ {
+		p.buf.WriteByte('\n')
+	}
+	p.pendingNewlines = 0
+	p.writeIndent()
+}
+
+func (p *printer) writeIndent() {
+	for i := 0; i < p.indent; i++ {
+		p.buf.WriteByte('\t')
+	}
+}
+
+func (p *printer) writeToken(tok *token.Token) {
+	switch tok.Type {
+	case token.RightBrace:
+		if p.inInline() {
+			break
+		}
+		if p.inMultiline() && p.pendingNewlines == 0 {
+			p.pendingNewlines = 1
+		}
+		p.indent--
+		if p.last != nil && p.last.Type == token.LeftBrace && !p.commentAfterLast {
+			// empty block, like "class Bagel {}"
+			p.pendingNewlines = 0
+		}
+	case token.Else, token.Catch, token.Finally:
+		// unless a trailing comment of "}" is in the way
+		if p.last != nil && p.last.Type == token.RightBrace && !p.commentAfterLast {
+			p.pendingNewlines = 0
+		}
+	case token.Semicolon, token.RightParen, token.Comma:
+		if p.last != nil && p.last.Type == token.RightBrace && !p.commentAfterLast {
+			p.pendingNewlines = 0
+		}
+	}
+
+	if p.pendingNewlines > 0 {
+		p.writeNewlines(tok.Line, tok.Type == token.RightBrace)
+	} else if p.buf.Len() > 0 && p.needSpace(tok) {
+		p.buf.WriteByte(' ')
+	}
+
+	p.buf.WriteString(tok.Lexeme)
+
+	lastIsFun := p.last != nil && p.last.Type == token.Fun
+	lastIsParams := p.lastClosesParams
+	opensLiteral := tok.Type == token.LeftBrace && p.expectsOperand(p.last)
+	opensNames := tok.Type == token.LeftBrace && p.last != nil && p.last.Type == token.Import
+	p.lastIsUnary = tok.Type == token.Bang || (tok.Type == token.Minus && !p.endsOperand(p.last))
+	p.lastClosesParams = false
+	p.lastClosesExpr = false
+	p.last = tok
+	// multi-line strings
+	p.lastLine = tok.Line + strings.Count(tok.Lexeme, "\n")
+	p.commentAfterLast = false
+
+	switch tok.Type {
+	case token.Question:
+		p.questions = append(p.questions, p.depth())
+	case token.Colon:
+		if p.inConditional() {
+			p.questions = p.questions[:len(p.questions)-1]
+		}
+	case token.LeftParen:
+		p.parens = append(p.parens, lastIsFun)
+	case token.RightParen:
+		if len(p.parens) > 0 {
+			p.lastClosesParams = p.parens[len(p.parens)-1]
+			p.parens = p.parens[:len(p.parens)-1]
+		}
+	case token.LeftBracket:
+		p.brackets++
+	case token.RightBracket:
+		p.brackets--
+	case token.LeftBrace:
+		opened := brace{
+			lambda:       lastIsParams,
+			literal:      opensLiteral,
+			names:        opensNames,
+			parenDepth:   len(p.parens),
+			bracketDepth: p.brackets,
+		}
+		opened.multiline = (opensLiteral || opensNames) && p.commentsBeforeClosing()
+		p.braces = append(p.braces, opened)
+		if !opened.inline() {
+			p.indent++
+			p.pendingNewlines = 1
+		}
+	case token.RightBrace:
+		p.pendingNewlines = 1
+		if len(p.braces) > 0 {
+			// anonymous functions and map literals are expressions, so they go on
+			closed := p.braces[len(p.braces)-1]
+			p.lastClosesExpr = closed.lambda || closed.literal
+			if p.lastClosesExpr || closed.names {
+				p.pendingNewlines = 0
+			}
+			p.braces = p.braces[:len(p.braces)-1]
+		}
+	case token.Comma:
+		// one entry per line
+		if p.inMultiline() && len(p.parens) == p.braceParenDepth() &&
+			p.brackets == p.braces[len(p.braces)-1].bracketDepth {
+			p.pendingNewlines = 1
+		}
+	case token.Semicolon:
+		// no newline between clauses of for
+		if len(p.parens) == p.braceParenDepth() {
+			p.pendingNewlines = 1
+		}
+	}
+}
+
+// inInline reports whether the innermost brace stays on one line.
+func (p *printer) inInline() bool {
+	return len(p.braces) > 0 && p.braces[len(p.braces)-1].inline()
+}
+
+// inMultiline reports whether the innermost brace opens
+// a map literal or names taking one entry per line.
+func (p *printer) inMultiline() bool {
+	return len(p.braces) > 0 && p.braces[len(p.braces)-1].multiline
+}
+
+// commentsBeforeClosing reports whether any comment is
+// between the brace just written and its closing brace.
+func (p *printer) commentsBeforeClosing() bool {
+	if p.nextComment >= len(p.comments) {
+		return false
+	}
+
+	depth := 0
+	for _, tok := range p.tokens[p.current:] {
+		switch tok.Type {
+		case token.LeftBrace:
+			depth++
+		case token.RightBrace:
+			depth--
+		}
+		if depth == 0 {
+			return p.comments[p.nextComment].Offset < tok.Offset
+		}
+	}
+
+	return false
+}
+
+// expectsOperand reports whether an operand should come after tok,
+// so that a following "{" opens a map literal instead of a block.
+func (p *printer) expectsOperand(tok *token.Token) bool {
+	if tok == nil || p.endsOperand(tok) {
+		return false
+	}
+
+	switch tok.Type {
+	case token.Semicolon, token.LeftBrace, token.RightBrace, token.Else, token.Try, token.Finally, token.Import:
+		return false
+	}
+
+	return true
+}
+
+// depth returns how many parentheses and braces enclose the next token.
+func (p *printer) depth() int {
+	return len(p.parens) + len(p.braces)
+}
+
+// inConditional reports whether a ":" here belongs to a conditional expression,
+// rather than an entry of a map literal.
+func (p *printer) inConditional() bool {
+	return len(p.questions) > 0 && p.questions[len(p.questions)-1] == p.depth()
+}
+
+// braceParenDepth returns how many parentheses enclose the innermost brace.
+func (p *printer) braceParenDepth() int {
+	if len(p.braces) == 0 {
+		return 0
+	}
+
+	return p.braces[len(p.braces)-1].parenDepth
+}
+
+// needSpace reports whether a space is needed between the last token and tok.
+func (p *printer) needSpace(tok *token.Token) bool {
+	last := p.last
+	if last == nil {
+		return false
+	}
+
+	switch tok.Type {
+	case token.RightParen, token.RightBracket, token.Comma, token.Dot, token.Semicolon:
+		return false
+	case token.Colon:
+		return p.inConditional()
+	case token.RightBrace:
+		if p.inInline() {
+			return false
+		}
+	case token.LeftParen, token.LeftBracket:
+		// calls, indexes and declarations, the keywords are followed by a space
+		if p.endsOperand(last) {
+			return false
+		}
+	case token.Interpolation, token.String:
+		// the rest of a string after an embedded expression, like `} b"`
+		if strings.HasPrefix(tok.Lexeme, "}") {
+			return false
+		}
+	}
+
+	switch last.Type {
+	case token.LeftParen, token.LeftBracket, token.Dot, token.Interpolation:
+		return false
+	case token.LeftBrace:
+		if p.inInline() {
+			return false
+		}
+		// empty block
+		return tok.Type != token.RightBrace
+	}
+
+	return !p.lastIsUnary
+}
+
+// endsOperand reports whether tok can be the end of an operand,
+// so that a following "-" is binary and a following "(" is a call.
+func (p *printer) endsOperand(tok *token.Token) bool {
+	if tok == nil {
+		return false
+	}
+
+	switch tok.Type {
+	case token.Identifier, token.Number, token.String,
+		token.True, token.False, token.Nil, token.This,
+		token.RightParen, token.RightBracket:
+		return true
+	case token.RightBrace:
+		return tok == p.last && p.lastClosesExpr
+	}
+
+	return false
+}
@@ -0,0 +1,354 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/nanmu42/bluelox/scanner"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "empty",
+			source: "",
+			want:   "",
+		},
+		{
+			name:   "spacing",
+			source: `var   a=1 ;var b = -a*(2+3) ;print !true;print a - -b;`,
+			want: `var a = 1;
+var b = -a * (2 + 3);
+print !true;
+print a - -b;
+`,
+		},
+		{
+			name: "blocks",
+			source: `fun   add( x,y ){return x+y;}
+class Bagel{}
+class A < Bagel {
+  init(n) { this.n=n; }
+
+
+
+  get(){
+     return this.n ; }
+}
+if(a>=1){print "yes";}else if (!true) print"no"; else {
+}
+for(var i=0;i<3;i=i+1) print i;
+for(;;){ }
+`,
+			want: `fun add(x, y) {
+	return x + y;
+}
+class Bagel {}
+class A < Bagel {
+	init(n) {
+		this.n = n;
+	}
+
+	get() {
+		return this.n;
+	}
+}
+if (a >= 1) {
+	print "yes";
+} else if (!true) print "no";
+else {}
+for (var i = 0; i < 3; i = i + 1) print i;
+for (;;) {}
+`,
+		},
+		{
+			name: "comments",
+			source: `// header
+
+var a = 1;   // trailing
+{ // after brace
+// inside
+    print a;
+
+    // before brace
+}
+// tail`,
+			want: `// header
+
+var a = 1; // trailing
+{ // after brace
+	// inside
+	print a;
+
+	// before brace
+}
+// tail
+`,
+		},
+		{
+			name:   "UTF8",
+			source: `print "你好，世界！";// 滚滚长江东逝水`,
+			want: `print "你好，世界！"; // 滚滚长江东逝水
+`,
+		},
+		{
+			name: "anonymous functions",
+			source: `var double=fun(a){return a*2;};
+print apply(fun (x) {return x+1;}, 2);
+fun(){print "iife";}();`,
+			want: `var double = fun (a) {
+	return a * 2;
+};
+print apply(fun (x) {
+	return x + 1;
+}, 2);
+fun () {
+	print "iife";
+}();
+`,
+		},
+		{
+			name:   "lists",
+			source: `var xs=[ 1,-2 ,[]];xs [0]=xs[ 1 ];print xs.slice(0,1)[0];`,
+			want: `var xs = [1, -2, []];
+xs[0] = xs[1];
+print xs.slice(0, 1)[0];
+`,
+		},
+		{
+			name: "maps",
+			source: `var m={ "a":1,"b" :{},"c":[1,{2:3}]};m["x"]={};
+if(true){print {};}`,
+			want: `var m = {"a": 1, "b": {}, "c": [1, {2: 3}]};
+m["x"] = {};
+if (true) {
+	print {};
+}
+`,
+		},
+		{
+			name:   "getters and static methods",
+			source: `class Math{class square(n){return n*n;} pi{return 3;}}`,
+			want: `class Math {
+	class square(n) {
+		return n * n;
+	}
+	pi {
+		return 3;
+	}
+}
+`,
+		},
+		{
+			name:   "string interpolation",
+			source: `print "a=${ a+1 }, b=${b[ 0 ]}${ -c }, m=${ {"k":1}["k"] }, ${"in${x}ner"}";`,
+			want: `print "a=${a + 1}, b=${b[0]}${-c}, m=${{"k": 1}["k"]}, ${"in${x}ner"}";
+`,
+		},
+		{
+			name:   "operators",
+			source: `var a=b%2==0?-c**2:{"k":d?1:2};a+=1;x.y*=-2;xs[0]/=2**-1;a-=b?c:d?e:f;`,
+			want: `var a = b % 2 == 0 ? -c ** 2 : {"k": d ? 1 : 2};
+a += 1;
+x.y *= -2;
+xs[0] /= 2 ** -1;
+a -= b ? c : d ? e : f;
+`,
+		},
+		{
+			name: "exceptions",
+			source: `try{throw "x";}catch(e){print e;}
+finally{print 1;}
+try { f(); } finally {}`,
+			want: `try {
+	throw "x";
+} catch (e) {
+	print e;
+} finally {
+	print 1;
+}
+try {
+	f();
+} finally {}
+`,
+		},
+		{
+			name:   "modules",
+			source: `import{a,b}from"x.lox";import "y.lox";export fun f(){return a;}export var v=b;`,
+			want: `import {a, b} from "x.lox";
+import "y.lox";
+export fun f() {
+	return a;
+}
+export var v = b;
+`,
+		},
+		{
+			name: "maps with comments",
+			source: `var m = {"a": 1, // one
+"b": [1, 2], "c": {"d": 3}
+// last
+};
+print {  // only
+};
+var n = {"x": {"y": 1, // inner
+"z": fun (a, b) {return a;}}};`,
+			want: `var m = {
+	"a": 1, // one
+	"b": [1, 2],
+	"c": {"d": 3}
+	// last
+};
+print { // only
+};
+var n = {
+	"x": {
+		"y": 1, // inner
+		"z": fun (a, b) {
+			return a;
+		}
+	}
+};
+`,
+		},
+		{
+			name: "imports with comments",
+			source: `import {a, // first
+b} from "x.lox";
+import{c}from"y.lox";`,
+			want: `import {
+	a, // first
+	b
+} from "x.lox";
+import {c} from "y.lox";
+`,
+		},
+		{
+			name: "comments before else",
+			source: `if (a) { print 1; } // after if
+else { print 2; }`,
+			want: `if (a) {
+	print 1;
+} // after if
+else {
+	print 2;
+}
+`,
+		},
+		{
+			name: "comments before catch and finally",
+			source: `try { f(); } // c
+catch (e) { print e; } // f
+finally { print 1; }
+try { f(); } // only finally
+finally {}`,
+			want: `try {
+	f();
+} // c
+catch (e) {
+	print e;
+} // f
+finally {
+	print 1;
+}
+try {
+	f();
+} // only finally
+finally {}
+`,
+		},
+		{
+			name: "comments after anonymous functions",
+			source: `foo(fun () {} // c
+, 2);
+foo(fun () {} // d
+);
+var f = fun () {} // e
+;`,
+			want: `foo(fun () {} // c
+, 2);
+foo(fun () {} // d
+);
+var f = fun () {} // e
+;
+`,
+		},
+		{
+			name:    "bad syntax",
+			source:  `print (1;`,
+			wantErr: true,
+		},
+		{
+			name:    "bad token",
+			source:  `print @;`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Source([]byte(tt.source))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+
+			// formatting is idempotent
+			again, err := Source(got)
+			require.NoError(t, err)
+			require.Equal(t, string(got), string(again))
+		})
+	}
+}
+
+func Test_sameTokens(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		formatted string
+		wantErr   string
+	}{
+		{
+			name:      "same",
+			src:       "print  1;// one",
+			formatted: "print 1; // one\n",
+		},
+		{
+			name:      "swallowed by a comment",
+			src:       "} // c\nelse",
+			formatted: "} // c else",
+			wantErr:   `Else "else" at 2:1 becomes EOF ""`,
+		},
+		{
+			name:      "missing",
+			src:       "print 1;",
+			formatted: "print 1",
+			wantErr:   `Semicolon ";" at 1:8 becomes EOF ""`,
+		},
+		{
+			name:      "unexpected",
+			src:       "print 1",
+			formatted: "print 1;",
+			wantErr:   `EOF "" at 1:8 becomes Semicolon ";"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := scanner.NewScanner([]byte(tt.src)).ScanTokens()
+			require.NoError(t, err)
+
+			err = sameTokens(want, []byte(tt.formatted))
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
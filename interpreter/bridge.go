@@ -0,0 +1,423 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/nanmu42/bluelox/diag"
+	"github.com/nanmu42/bluelox/token"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// GoObject is a Go struct seen from Lox,
+// exported fields and methods of the struct are its properties.
+type GoObject struct {
+	// pointer to the struct, never nil
+	value reflect.Value
+}
+
+// Unwrap returns the pointer to the struct.
+func (o *GoObject) Unwrap() interface{} {
+	return o.value.Interface()
+}
+
+func (o *GoObject) String() string {
+	return fmt.Sprint(o.value.Elem().Interface())
+}
+
+// Get returns the exported field or method of the struct.
+func (o *GoObject) Get(name *token.Token) (property interface{}, err error) {
+	if field, ok := o.field(name.Lexeme); ok {
+		if field.Kind() == reflect.Struct {
+			// so that changes of the nested struct stay in the struct
+			return &GoObject{value: field.Addr()}, nil
+		}
+
+		property, err = toValue(field)
+		if err != nil {
+			err = &RuntimeError{
+				Reason: fmt.Sprintf("reading field %q: %s", name.Lexeme, err),
+				Token:  name,
+				Err:    err,
+			}
+			return
+		}
+
+		return
+	}
+
+	method := o.value.MethodByName(name.Lexeme)
+	if method.IsValid() {
+		return &goFunc{fn: method}, nil
+	}
+
+	err = &RuntimeError{
+		Reason: fmt.Sprintf("undefined property %q of %s", name.Lexeme, o.value.Type().Elem()),
+		Token:  name,
+		Code:   diag.CodeUndefinedProperty,
+	}
+	return
+}
+
+// SetField assigns the exported field of the struct.
+func (o *GoObject) SetField(name *token.Token, value interface{}) (err error) {
+	field, ok := o.field(name.Lexeme)
+	if !ok {
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("undefined field %q of %s", name.Lexeme, o.value.Type().Elem()),
+			Token:  name,
+			Code:   diag.CodeUndefinedProperty,
+		}
+		return
+	}
+
+	converted, err := fromValue(value, field.Type())
+	if err != nil {
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("assigning field %q: %s", name.Lexeme, err),
+			Token:  name,
+			Code:   diag.CodeOperandType,
+			Err:    err,
+		}
+		return
+	}
+
+	field.Set(converted)
+	return
+}
+
+// field returns the exported field name of the struct.
+func (o *GoObject) field(name string) (field reflect.Value, ok bool) {
+	structField, ok := o.value.Type().Elem().FieldByName(name)
+	if !ok || !structField.IsExported() {
+		return reflect.Value{}, false
+	}
+
+	return o.value.Elem().FieldByIndex(structField.Index), true
+}
+
+// goFunc is a Go function called by reflection.
+//
+// Arguments are converted by their parameter types,
+// the last parameter of variadic functions takes a list.
+// A non-nil error as the last result becomes a runtime error,
+// other results are converted into a value, or a list of values if there are many.
+// Panics of the function become runtime errors as well.
+type goFunc struct {
+	fn reflect.Value
+}
+
+func (g *goFunc) Arity() int {
+	return g.fn.Type().NumIn()
+}
+
+func (g *goFunc) Call(interpreter *Interpreter, arguments []interface{}) (result interface{}, err error) {
+	defer func() {
+		// the host application should not crash because of one bad function
+		if reason := recover(); reason != nil {
+			result = nil
+			err = fmt.Errorf("Go function panicked: %v", reason)
+		}
+	}()
+
+	fnType := g.fn.Type()
+
+	in := make([]reflect.Value, len(arguments))
+	for index, argument := range arguments {
+		in[index], err = fromValue(argument, fnType.In(index))
+		if err != nil {
+			err = fmt.Errorf("argument at index %d: %w", index, err)
+			return
+		}
+	}
+
+	var out []reflect.Value
+	if fnType.IsVariadic() {
+		out = g.fn.CallSlice(in)
+	} else {
+		out = g.fn.Call(in)
+	}
+
+	if n := fnType.NumOut(); n > 0 && fnType.Out(n-1) == errorType {
+		if last := out[n-1]; !last.IsNil() {
+			err = last.Interface().(error)
+			return
+		}
+		out = out[:n-1]
+	}
+
+	switch len(out) {
+	case 0:
+		return nil, nil
+	case 1:
+		return toValue(out[0])
+	}
+
+	elements := make([]interface{}, len(out))
+	for index, item := range out {
+		elements[index], err = toValue(item)
+		if err != nil {
+			err = fmt.Errorf("result %d: %w", index, err)
+			return
+		}
+	}
+
+	return NewList(elements), nil
+}
+
+func (g *goFunc) String() string {
+	return nativeFuncStringForm
+}
+
+// ToValue converts v from Go into Lox.
+//
+// Numbers become float64, slices and arrays become lists, maps become maps,
+// structs and pointers to structs become GoObject, and functions become callable.
+// Values satisfying IsValue are kept as is.
+//
+// Structs are copied, while pointers to structs are shared with Lox.
+// Slices, arrays and maps are always copied, even in fields of shared structs,
+// so changing their elements in Lox does not change them in Go,
+// while assigning the fields does.
+func ToValue(v interface{}) (value interface{}, err error) {
+	return toValue(reflect.ValueOf(v))
+}
+
+func toValue(v reflect.Value) (value interface{}, err error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.CanInterface() && IsValue(v.Interface()) {
+		return v.Interface(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]interface{}, v.Len())
+		for index := range elements {
+			elements[index], err = toValue(v.Index(index))
+			if err != nil {
+				err = fmt.Errorf("element %d: %w", index, err)
+				return
+			}
+		}
+		return NewList(elements), nil
+	case reflect.Map:
+		m := NewMap()
+		iter := v.MapRange()
+		for iter.Next() {
+			var key, element interface{}
+			key, err = toValue(iter.Key())
+			if err != nil {
+				err = fmt.Errorf("key %v: %w", iter.Key(), err)
+				return
+			}
+			element, err = toValue(iter.Value())
+			if err != nil {
+				err = fmt.Errorf("value of key %v: %w", iter.Key(), err)
+				return
+			}
+			err = m.Store(key, element)
+			if err != nil {
+				return
+			}
+		}
+		return m, nil
+	case reflect.Struct:
+		copied := reflect.New(v.Type())
+		copied.Elem().Set(v)
+		return &GoObject{value: copied}, nil
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Elem().Kind() == reflect.Struct {
+			return &GoObject{value: v}, nil
+		}
+		return toValue(v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return toValue(v.Elem())
+	case reflect.Func:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return &goFunc{fn: v}, nil
+	}
+
+	err = fmt.Errorf("can not convert %s into a Lox value", v.Type())
+	return
+}
+
+// fromValue converts value from Lox into Go type t.
+func fromValue(value interface{}, t reflect.Type) (converted reflect.Value, err error) {
+	if object, ok := value.(*GoObject); ok {
+		switch {
+		case object.value.Type().AssignableTo(t):
+			return object.value, nil
+		case object.value.Type().Elem().AssignableTo(t):
+			return object.value.Elem(), nil
+		}
+		err = fmt.Errorf("can not convert %s into %s", object.value.Type(), t)
+		return
+	}
+
+	if value == nil {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface, reflect.Func:
+			return reflect.Zero(t), nil
+		}
+		err = fmt.Errorf("can not convert nil into %s", t)
+		return
+	}
+
+	if t.Kind() == reflect.Interface {
+		v := reflect.ValueOf(value)
+		if !v.Type().Implements(t) {
+			err = fmt.Errorf("%T does not implement %s", value, t)
+			return
+		}
+		converted = reflect.New(t).Elem()
+		converted.Set(v)
+		return
+	}
+
+	converted = reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, ok := value.(bool); ok {
+			converted.SetBool(b)
+			return
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := value.(float64); ok {
+			if n != math.Trunc(n) || converted.OverflowInt(int64(n)) {
+				err = fmt.Errorf("number %v does not fit in %s", n, t)
+				return
+			}
+			converted.SetInt(int64(n))
+			return
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n, ok := value.(float64); ok {
+			if n != math.Trunc(n) || n < 0 || converted.OverflowUint(uint64(n)) {
+				err = fmt.Errorf("number %v does not fit in %s", n, t)
+				return
+			}
+			converted.SetUint(uint64(n))
+			return
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, ok := value.(float64); ok {
+			converted.SetFloat(n)
+			return
+		}
+	case reflect.String:
+		if s, ok := value.(string); ok {
+			converted.SetString(s)
+			return
+		}
+	case reflect.Slice:
+		if list, ok := value.(*List); ok {
+			converted = reflect.MakeSlice(t, len(list.Elements), len(list.Elements))
+			err = fromElements(list, converted)
+			return
+		}
+	case reflect.Array:
+		if list, ok := value.(*List); ok {
+			if len(list.Elements) != t.Len() {
+				err = fmt.Errorf("can not convert list of length %d into %s", len(list.Elements), t)
+				return
+			}
+			err = fromElements(list, converted)
+			return
+		}
+	case reflect.Map:
+		if m, ok := value.(*Map); ok {
+			converted = reflect.MakeMapWithSize(t, m.Len())
+			for _, key := range m.Keys() {
+				var goKey, goElement reflect.Value
+				goKey, err = fromValue(key, t.Key())
+				if err != nil {
+					err = fmt.Errorf("key %s: %w", keyString(key), err)
+					return
+				}
+				element, _ := m.Load(key)
+				goElement, err = fromValue(element, t.Elem())
+				if err != nil {
+					err = fmt.Errorf("value of key %s: %w", keyString(key), err)
+					return
+				}
+				converted.SetMapIndex(goKey, goElement)
+			}
+			return
+		}
+	case reflect.Struct:
+		if m, ok := value.(*Map); ok {
+			object := &GoObject{value: converted.Addr()}
+			for _, key := range m.Keys() {
+				name, ok := key.(string)
+				if !ok {
+					err = fmt.Errorf("field name must be a string, got %s", keyString(key))
+					return
+				}
+				field, ok := object.field(name)
+				if !ok {
+					err = fmt.Errorf("undefined field %q of %s", name, t)
+					return
+				}
+				element, _ := m.Load(key)
+				var goElement reflect.Value
+				goElement, err = fromValue(element, field.Type())
+				if err != nil {
+					err = fmt.Errorf("field %q: %w", name, err)
+					return
+				}
+				field.Set(goElement)
+			}
+			return
+		}
+	case reflect.Ptr:
+		var elem reflect.Value
+		elem, err = fromValue(value, t.Elem())
+		if err != nil {
+			return
+		}
+		converted = reflect.New(t.Elem())
+		converted.Elem().Set(elem)
+		return
+	}
+
+	name, _ := typeName(value)
+	err = fmt.Errorf("can not convert %s into %s", name, t)
+	return
+}
+
+// fromElements converts elements of list into slice or array v.
+func fromElements(list *List, v reflect.Value) (err error) {
+	for index, element := range list.Elements {
+		var goElement reflect.Value
+		goElement, err = fromValue(element, v.Type().Elem())
+		if err != nil {
+			err = fmt.Errorf("element %d: %w", index, err)
+			return
+		}
+		v.Index(index).Set(goElement)
+	}
+
+	return
+}
@@ -0,0 +1,97 @@
+package interpreter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type bridgeUser struct {
+	Name   string
+	Age    int
+	Tags   []string
+	secret string
+}
+
+func TestToValue_roundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		goValue   interface{}
+		wantValue interface{}
+	}{
+		{"nil", nil, nil},
+		{"bool", true, true},
+		{"int", 3, float64(3)},
+		{"uint8", uint8(255), float64(255)},
+		{"float32", float32(0.5), 0.5},
+		{"string", "lox", "lox"},
+		{"slice", []int{1, 2}, NewList([]interface{}{float64(1), float64(2)})},
+		{"array", [2]string{"a", "b"}, NewList([]interface{}{"a", "b"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := ToValue(tt.goValue)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantValue, value)
+
+			if tt.goValue == nil {
+				return
+			}
+			back, err := fromValue(value, reflect.TypeOf(tt.goValue))
+			require.NoError(t, err)
+			require.Equal(t, tt.goValue, back.Interface())
+		})
+	}
+}
+
+func TestToValue_map(t *testing.T) {
+	value, err := ToValue(map[string]int{"a": 1})
+	require.NoError(t, err)
+	m, ok := value.(*Map)
+	require.True(t, ok)
+	got, ok := m.Load("a")
+	require.True(t, ok)
+	require.Equal(t, float64(1), got)
+
+	back, err := fromValue(m, reflect.TypeOf(map[string]int{}))
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"a": 1}, back.Interface())
+}
+
+func TestFromValue_struct(t *testing.T) {
+	m := NewMap()
+	require.NoError(t, m.Store("Name", "Ann"))
+	require.NoError(t, m.Store("Tags", NewList([]interface{}{"x"})))
+
+	got, err := fromValue(m, reflect.TypeOf(&bridgeUser{}))
+	require.NoError(t, err)
+	require.Equal(t, &bridgeUser{Name: "Ann", Tags: []string{"x"}}, got.Interface())
+
+	require.NoError(t, m.Store("secret", "s"))
+	_, err = fromValue(m, reflect.TypeOf(bridgeUser{}))
+	require.EqualError(t, err, `undefined field "secret" of interpreter.bridgeUser`)
+}
+
+func TestFromValue_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		goValue interface{}
+		wantErr string
+	}{
+		{"fraction into int", 1.5, 0, "number 1.5 does not fit in int"},
+		{"overflow", float64(256), uint8(0), "number 256 does not fit in uint8"},
+		{"negative into uint", float64(-1), uint(0), "number -1 does not fit in uint"},
+		{"string into number", "1", 0.0, "can not convert string into float64"},
+		{"nil into string", nil, "", "can not convert nil into string"},
+		{"list element", NewList([]interface{}{"a", 1.0}), []string{}, "element 1: can not convert number into string"},
+		{"array length", NewList([]interface{}{"a"}), [2]string{}, "can not convert list of length 1 into [2]string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fromValue(tt.value, reflect.TypeOf(tt.goValue))
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
@@ -1,11 +1,13 @@
 package interpreter
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"time"
 
 	"github.com/nanmu42/bluelox/ast"
+	"github.com/nanmu42/bluelox/token"
 )
 
 const nativeFuncStringForm = "<native fn>"
@@ -17,63 +19,91 @@ type Callable interface {
 }
 
 type Function struct {
-	Declaration   *ast.FunctionStmt
+	// Name is nil for anonymous functions
+	Name          *token.Token
+	Params        []*token.Token
+	Body          []ast.Statement
 	Closure       *Environment
 	IsInitializer bool
+	// IsGetter is set for getters, which are called when the property is accessed
+	IsGetter bool
+	// Class which the method belongs to, nil for functions
+	Class *Class
 }
 
 func (f *Function) Arity() int {
-	return len(f.Declaration.Params)
+	return len(f.Params)
 }
 
 func (f *Function) Call(interpreter *Interpreter, arguments []interface{}) (result interface{}, err error) {
 	env := NewChildEnvironment(f.Closure)
-	for i, param := range f.Declaration.Params {
+	for i, param := range f.Params {
 		env.Define(param.Lexeme, arguments[i])
 	}
 
-	defer func() {
-		if f.IsInitializer {
-			var badThis error
-			result, badThis = f.Closure.GetAt(0, "this")
-			if badThis != nil {
-				panic(badThis)
-			}
-		}
-	}()
-	defer func() {
-		panicReason := recover()
-		if panicReason == nil {
-			return
-		}
-		returnValue, ok := panicReason.(*returnPayload)
-		if !ok {
-			panic(panicReason)
-		}
-
-		result = returnValue.Value
-	}()
-
-	err = interpreter.executeBlock(f.Declaration.Body, env)
+	err = interpreter.executeBlock(f.Body, env)
+	if err == errReturn {
+		result, err = interpreter.returnValue, nil
+		interpreter.returnValue = nil
+	}
+	if err != nil {
+		return
+	}
+
+	if f.IsInitializer {
+		result, err = f.Closure.GetAt(0, "this")
+	}
+
 	return
 }
 
 func (f *Function) String() string {
-	return fmt.Sprintf("<fn %s>", f.Declaration.Name.Lexeme)
+	if f.Name == nil {
+		return "<anonymous fn>"
+	}
+
+	return fmt.Sprintf("<fn %s>", f.Name.Lexeme)
 }
 
-func (f *Function) Bind(i *Instance) *Function {
+// Bind returns a copy of f in which "this" is this,
+// which is an *Instance for methods and the *Class for static methods.
+func (f *Function) Bind(this interface{}) *Function {
 	env := NewChildEnvironment(f.Closure)
-	env.Define("this", i)
+	env.Define("this", this)
 	return &Function{
-		Declaration:   f.Declaration,
+		Name:          f.Name,
+		Params:        f.Params,
+		Body:          f.Body,
 		Closure:       env,
 		IsInitializer: f.IsInitializer,
+		IsGetter:      f.IsGetter,
+		Class:         f.Class,
 	}
 }
 
-type returnPayload struct {
-	Value interface{}
+// errReturn is not an error, but a sentinel which unwinds
+// statements up to the function call when a return statement executes.
+// The returned value is kept in Interpreter.returnValue.
+//
+// Statements must pass it up as is, without wrapping.
+var errReturn = errors.New("return statement out of function")
+
+// nativeMethod is a built-in method bound to a value, like push of a list.
+type nativeMethod struct {
+	arity int
+	fn    func(arguments []interface{}) (result interface{}, err error)
+}
+
+func (n *nativeMethod) Arity() int {
+	return n.arity
+}
+
+func (n *nativeMethod) Call(interpreter *Interpreter, arguments []interface{}) (result interface{}, err error) {
+	return n.fn(arguments)
+}
+
+func (n *nativeMethod) String() string {
+	return nativeFuncStringForm
 }
 
 type nativeFuncClock struct{}
@@ -83,7 +113,8 @@ func (n nativeFuncClock) Arity() int {
 }
 
 func (n nativeFuncClock) Call(interpreter *Interpreter, arguments []interface{}) (result interface{}, err error) {
-	return float64(time.Now().Unix()), nil
+	// seconds with fraction, so that it's useful for timing
+	return float64(time.Now().UnixNano()) / float64(time.Second), nil
 }
 
 func (n nativeFuncClock) String() string {
@@ -103,8 +134,16 @@ func (n nativeFuncSleep) Call(interpreter *Interpreter, arguments []interface{})
 		return
 	}
 
-	time.Sleep(time.Duration(ms) * time.Millisecond)
-	return nil, nil
+	timer := time.NewTimer(time.Duration(ms) * time.Millisecond)
+	defer timer.Stop()
+
+	// wake up early if the run is cancelled
+	select {
+	case <-timer.C:
+		return nil, nil
+	case <-interpreter.ctx.Done():
+		return nil, interpreter.ctx.Err()
+	}
 }
 
 func (n nativeFuncSleep) String() string {
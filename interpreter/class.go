@@ -3,6 +3,7 @@ package interpreter
 import (
 	"fmt"
 
+	"github.com/nanmu42/bluelox/diag"
 	"github.com/nanmu42/bluelox/token"
 )
 
@@ -10,6 +11,8 @@ type Class struct {
 	Name       string
 	SuperClass *Class
 	Methods    map[string]*Function
+	// StaticMethods are called on the class itself
+	StaticMethods map[string]*Function
 }
 
 func (c *Class) String() string {
@@ -46,6 +49,30 @@ func (c *Class) FindMethod(name string) (method *Function, ok bool) {
 	return
 }
 
+// FindStaticMethod looks up a static method in c and its superclasses.
+func (c *Class) FindStaticMethod(name string) (method *Function, ok bool) {
+	method, ok = c.StaticMethods[name]
+	if !ok && c.SuperClass != nil {
+		method, ok = c.SuperClass.FindStaticMethod(name)
+	}
+	return
+}
+
+// Get returns the static method bound to c.
+func (c *Class) Get(name *token.Token) (property interface{}, err error) {
+	method, ok := c.FindStaticMethod(name.Lexeme)
+	if ok {
+		return method.Bind(c), nil
+	}
+
+	err = &RuntimeError{
+		Reason: fmt.Sprintf("undefined static property %q of class %s", name.Lexeme, c.Name),
+		Token:  name,
+		Code:   diag.CodeUndefinedProperty,
+	}
+	return
+}
+
 type Instance struct {
 	class  *Class
 	fields map[string]interface{}
@@ -67,7 +94,11 @@ func (i *Instance) Get(name *token.Token) (property interface{}, err error) {
 		return method.Bind(i), nil
 	}
 
-	err = fmt.Errorf("undefiend property %q at line %d", name.Lexeme, name.Line)
+	err = &RuntimeError{
+		Reason: fmt.Sprintf("undefined property %q", name.Lexeme),
+		Token:  name,
+		Code:   diag.CodeUndefinedProperty,
+	}
 	return
 }
 
@@ -75,6 +106,12 @@ func (i *Instance) Set(name *token.Token, result interface{}) {
 	i.fields[name.Lexeme] = result
 }
 
+// SetField is Set for fieldHolder.
+func (i *Instance) SetField(name *token.Token, value interface{}) (err error) {
+	i.Set(name, value)
+	return
+}
+
 func NewInstance(class *Class) *Instance {
 	return &Instance{
 		class:  class,
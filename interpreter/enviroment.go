@@ -3,31 +3,36 @@ package interpreter
 import (
 	"fmt"
 
+	"github.com/nanmu42/bluelox/diag"
 	"github.com/nanmu42/bluelox/token"
 )
 
 type Environment struct {
 	values map[string]interface{}
 	parent *Environment
+	// globals is the root of the environment chain,
+	// which is the global environment of the module.
+	globals *Environment
 }
 
 func NewGlobalEnvironment() (env *Environment) {
 	env = &Environment{
 		values: make(map[string]interface{}),
 	}
+	env.globals = env
 
-	// native functions
-	env.Define("clock", nativeFuncClock{})
-	env.Define("sleep", nativeFuncSleep{})
-	env.Define("randN", nativeFuncRandN{})
+	for name, native := range stdlib {
+		env.Define(name, native)
+	}
 
 	return
 }
 
 func NewChildEnvironment(parent *Environment) *Environment {
 	return &Environment{
-		values: make(map[string]interface{}),
-		parent: parent,
+		values:  make(map[string]interface{}),
+		parent:  parent,
+		globals: parent.globals,
 	}
 }
 
@@ -45,7 +50,11 @@ func (e *Environment) Get(name *token.Token) (value interface{}, err error) {
 		return e.parent.Get(name)
 	}
 
-	err = fmt.Errorf("undefined variable %q", name.Lexeme)
+	err = &RuntimeError{
+		Reason: fmt.Sprintf("undefined variable %q", name.Lexeme),
+		Token:  name,
+		Code:   diag.CodeUndefinedVariable,
+	}
 	return
 }
 
@@ -60,7 +69,11 @@ func (e *Environment) Assign(name *token.Token, value interface{}) (err error) {
 		return e.parent.Assign(name, value)
 	}
 
-	err = fmt.Errorf("can not assign undecleared variable %q", name.Lexeme)
+	err = &RuntimeError{
+		Reason: fmt.Sprintf("can not assign undecleared variable %q", name.Lexeme),
+		Token:  name,
+		Code:   diag.CodeUndefinedVariable,
+	}
 	return
 }
 
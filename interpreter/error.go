@@ -3,18 +3,54 @@ package interpreter
 import (
 	"fmt"
 
+	"github.com/nanmu42/bluelox/diag"
 	"github.com/nanmu42/bluelox/token"
 )
 
 type RuntimeError struct {
 	Reason string
 	Token  *token.Token
+	// Code is optional, diag.CodeRuntime is used when empty.
+	Code diag.Code
+	// Trace is the call stack where the error happens,
+	// empty if it happens out of any function.
+	Trace StackTrace
+	// Err is the cause, optional
+	Err error
+	// Value is what a throw statement throws,
+	// only for errors with diag.CodeUncaughtException.
+	Value interface{}
 }
 
 func (r RuntimeError) Error() string {
 	if r.Token != nil && r.Token.Type > 0 {
-		return fmt.Sprintf("operation %q at line %d: %s", r.Token.Type, r.Token.Line, r.Reason)
+		if r.Token.File != "" {
+			return fmt.Sprintf("operation %q at %s:%s: %s", r.Token.Lexeme, r.Token.File, r.Token.Span(), r.Reason)
+		}
+		return fmt.Sprintf("operation %q at %s: %s", r.Token.Lexeme, r.Token.Span(), r.Reason)
 	}
 
 	return r.Reason
 }
+
+func (r *RuntimeError) Unwrap() error {
+	return r.Err
+}
+
+// Diagnostics implements diag.Diagnoser.
+func (r *RuntimeError) Diagnostics() diag.List {
+	d := &diag.Diagnostic{
+		Severity: diag.SeverityError,
+		Phase:    diag.PhaseRuntime,
+		Code:     r.Code,
+		Message:  r.Reason,
+	}
+	if d.Code == "" {
+		d.Code = diag.CodeRuntime
+	}
+	if r.Token != nil {
+		d.Span = r.Token.Span()
+	}
+
+	return diag.List{d}
+}
@@ -0,0 +1,59 @@
+package interpreter
+
+import (
+	"errors"
+
+	"github.com/nanmu42/bluelox/diag"
+)
+
+// errorClass is the class of errors caught from the interpreter,
+// whose instances have fields "message" and "line".
+var errorClass = &Class{
+	Name:          "Error",
+	Methods:       map[string]*Function{},
+	StaticMethods: map[string]*Function{},
+}
+
+// newErrorInstance exposes r to Lox as an instance of errorClass.
+func newErrorInstance(r *RuntimeError) *Instance {
+	instance := NewInstance(errorClass)
+	instance.fields["message"] = r.Reason
+
+	var line float64
+	if r.Token != nil {
+		line = float64(r.Token.Line)
+	}
+	instance.fields["line"] = line
+
+	return instance
+}
+
+// errorMessage returns the message of value if it's an error instance.
+func errorMessage(value interface{}) (message string, ok bool) {
+	instance, ok := value.(*Instance)
+	if !ok || instance.class != errorClass {
+		return "", false
+	}
+
+	message, ok = instance.fields["message"].(string)
+	return
+}
+
+// caught returns what a catch clause gets from err,
+// ok is false if err can not be caught, like sentinels of return, break and continue,
+// exceeding Limits or cancellation of the run.
+func caught(err error) (value interface{}, ok bool) {
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		return nil, false
+	}
+
+	if isLimitError(runtimeErr) || isCancellation(err) {
+		return nil, false
+	}
+	if runtimeErr.Code == diag.CodeUncaughtException {
+		return runtimeErr.Value, true
+	}
+
+	return newErrorInstance(runtimeErr), true
+}
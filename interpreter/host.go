@@ -0,0 +1,157 @@
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/nanmu42/bluelox/diag"
+	"github.com/nanmu42/bluelox/token"
+)
+
+// IsValue reports whether v is a value Lox knows how to work with,
+// which is nil, bool, float64, string, *List, *Map, *Class, *Instance, *GoObject, *Namespace or Callable.
+//
+// Elements of lists and maps are not checked.
+func IsValue(v interface{}) bool {
+	switch v.(type) {
+	case nil, bool, float64, string, *List, *Map, *Class, *Instance, *GoObject, *Namespace, Callable:
+		return true
+	}
+
+	return false
+}
+
+// NewNative returns a native function provided by the host application,
+// which takes arity arguments.
+//
+// fn must return a value satisfying IsValue, or the call ends up in a runtime error,
+// so does a panic of fn.
+func NewNative(arity int, fn func(arguments []interface{}) (result interface{}, err error)) Callable {
+	return pure(arity, func(arguments []interface{}) (result interface{}, err error) {
+		defer func() {
+			// the host application should not crash because of one bad native
+			if reason := recover(); reason != nil {
+				result = nil
+				err = fmt.Errorf("native function panicked: %v", reason)
+			}
+		}()
+
+		result, err = fn(arguments)
+		if err != nil {
+			return
+		}
+
+		if !IsValue(result) {
+			err = fmt.Errorf("native function returned %T, which is not a Lox value", result)
+			return
+		}
+
+		return
+	})
+}
+
+// DefineNative defines native as a global in the script and every module,
+// including modules imported later.
+func (i *Interpreter) DefineNative(name string, native Callable) {
+	i.natives[name] = native
+
+	i.globals.Define(name, native)
+	for _, m := range i.modules {
+		m.globals.Define(name, native)
+	}
+}
+
+// newGlobalEnvironment returns a global environment with natives of the host application.
+func (i *Interpreter) newGlobalEnvironment() (env *Environment) {
+	env = NewGlobalEnvironment()
+	for name, native := range i.natives {
+		env.Define(name, native)
+	}
+
+	return
+}
+
+// SetGlobal defines or assigns the global variable of the script.
+func (i *Interpreter) SetGlobal(name string, value interface{}) (err error) {
+	if !IsValue(value) {
+		err = fmt.Errorf("global %q of type %T is not a Lox value", name, value)
+		return
+	}
+
+	i.globals.Define(name, value)
+	return
+}
+
+// GetGlobal returns the global variable of the script, ok is false if it's not defined.
+func (i *Interpreter) GetGlobal(name string) (value interface{}, ok bool) {
+	value, ok = i.globals.values[name]
+	return
+}
+
+// hostCall is where the host application calls into Lox, which is in no script.
+var hostCall = &token.Token{Lexeme: "<host>"}
+
+// Call calls callable from the host application,
+// ctx is used in the same way as Interpret.
+//
+// Call can be used after Interpret, or inside natives during Interpret.
+func (i *Interpreter) Call(ctx context.Context, callable Callable, arguments []interface{}) (result interface{}, err error) {
+	previousCtx := i.ctx
+	i.ctx = ctx
+	if i.running == 0 {
+		i.resetUsage()
+	}
+	i.running++
+	defer func() {
+		i.ctx = previousCtx
+		i.running--
+	}()
+
+	if want, got := callable.Arity(), len(arguments); want != got {
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("function expected %d arguments but got %d", want, got),
+			Token:  hostCall,
+			Code:   diag.CodeArityMismatch,
+		}
+		return
+	}
+	for index, argument := range arguments {
+		if !IsValue(argument) {
+			err = fmt.Errorf("argument at index %d of type %T is not a Lox value", index, argument)
+			return
+		}
+	}
+
+	return i.call(callable, arguments, hostCall, token.Span{})
+}
+
+// Property returns the property name of object,
+// methods are bound to object, while getters are returned as is, instead of called.
+func Property(object interface{}, name string) (property interface{}, err error) {
+	holder, ok := object.(propertyHolder)
+	if !ok {
+		err = fmt.Errorf("%T does not have properties", object)
+		return
+	}
+
+	return holder.Get(&token.Token{Lexeme: name})
+}
+
+// FromValue converts value from Lox into what target points to,
+// which is the opposite of ToValue.
+func FromValue(value interface{}, target interface{}) (err error) {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		err = fmt.Errorf("target must be a non-nil pointer, got %T", target)
+		return
+	}
+
+	converted, err := fromValue(value, v.Type().Elem())
+	if err != nil {
+		return
+	}
+
+	v.Elem().Set(converted)
+	return
+}
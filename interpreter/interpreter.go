@@ -1,15 +1,19 @@
 package interpreter
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"math"
+	"reflect"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/nanmu42/bluelox/ast"
+	"github.com/nanmu42/bluelox/diag"
 	"github.com/nanmu42/bluelox/token"
 )
 
@@ -22,10 +26,34 @@ type Interpreter struct {
 	globals     *Environment
 	// keys are all pointers, so it's fine if we stick with one interpreter.
 	locals map[ast.Expression]int
+	// call stack of Lox functions
+	frames StackTrace
+	// value of the return statement being unwound, see errReturn
+	returnValue interface{}
+
+	// loads imported modules, imports fail without it
+	loader ModuleLoader
+	// modules imported so far, keyed by absolute path
+	modules map[string]*module
+	// the module being executed
+	module *module
+	// natives defined by the host application, see DefineNative
+	natives map[string]interface{}
+
+	limits Limits
+	// usage of the current run, see Limits
+	steps  int
+	memory int
+	// how many Interpret and Call are on the Go stack
+	running int
 
 	// protect stdout
 	stdoutMu sync.RWMutex
 	stdout   io.Writer
+	// read by IO.readLine, nil if scripts can not read input
+	stdin *bufio.Reader
+	// used by IO.readFile, nil if scripts can not read files
+	fileReader FileReader
 }
 
 func NewInterpreter(stdout io.Writer) *Interpreter {
@@ -35,6 +63,10 @@ func NewInterpreter(stdout io.Writer) *Interpreter {
 		environment: globals,
 		globals:     globals,
 		locals:      make(map[ast.Expression]int),
+		modules:     make(map[string]*module),
+		module:      newModule("", globals, nil),
+		natives:     make(map[string]interface{}),
+		limits:      Limits{MaxCallDepth: DefaultMaxCallDepth},
 		stdoutMu:    sync.RWMutex{},
 		stdout:      stdout,
 	}
@@ -46,26 +78,64 @@ func (i *Interpreter) ChangeStdoutTo(w io.Writer) {
 	i.stdoutMu.Unlock()
 }
 
+// ChangeStdinTo changes where IO.readLine reads from,
+// IO.readLine fails if r is nil, which is the default.
+func (i *Interpreter) ChangeStdinTo(r io.Reader) {
+	if r == nil {
+		i.stdin = nil
+		return
+	}
+
+	i.stdin = bufio.NewReader(r)
+}
+
+// FileReader returns the content of the file at path,
+// os.ReadFile is one which reads any file.
+type FileReader func(path string) (content []byte, err error)
+
+// SetFileReader sets how IO.readFile reads files,
+// IO.readFile fails if there is no reader, which is the default.
+func (i *Interpreter) SetFileReader(reader FileReader) {
+	i.fileReader = reader
+}
+
+// Interpret executes stmts of the script, ctx is used to stop it early.
+//
+// Interpret can be nested in natives called during another Interpret,
+// the nested run shares the call stack and usage of Limits with the outer one.
 func (i *Interpreter) Interpret(ctx context.Context, stmts []ast.Statement) (err error) {
+	previousCtx, previousEnv, previousModule := i.ctx, i.environment, i.module
 	i.ctx = ctx
-
+	if i.running == 0 {
+		// leftover of the last run if it panicked
+		i.frames = i.frames[:0]
+		i.resetUsage()
+	}
+	i.running++
 	defer func() {
-		if r := recover(); r != nil {
-			err = &RuntimeError{
-				Reason: fmt.Sprintf("runtime panicking: \n%v\n", r),
-			}
-		}
+		i.ctx, i.environment, i.module = previousCtx, previousEnv, previousModule
+		i.running--
 	}()
 
-	done := ctx.Done()
+	// runs nested in natives are at the top level of the script as well
+	i.environment = i.globals
+	for i.module.importer != nil {
+		i.module = i.module.importer
+	}
+
 	for _, stmt := range stmts {
-		select {
-		case <-done:
-			err = ctx.Err()
-		default:
-			// relax
+		err = i.cancelled()
+		if err != nil {
+			return
 		}
+
 		err = i.execute(stmt)
+		if err == errReturn || err == errBreak || err == errContinue {
+			// the resolver should have stopped this
+			err = &RuntimeError{
+				Reason: err.Error(),
+			}
+		}
 		if err != nil {
 			return
 		}
@@ -74,7 +144,29 @@ func (i *Interpreter) Interpret(ctx context.Context, stmts []ast.Statement) (err
 	return
 }
 
+// cancelled returns the error of the context if it's done,
+// which is checked on loops, calls and blocks so that runs can be stopped in time.
+func (i *Interpreter) cancelled() (err error) {
+	select {
+	case <-i.ctx.Done():
+		return i.ctx.Err()
+	default:
+		return nil
+	}
+}
+
+// isCancellation reports whether err comes from cancellation of the context,
+// which is passed up as is.
+func isCancellation(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
 func (i *Interpreter) evaluate(expr ast.Expression) (result interface{}, err error) {
+	err = i.step(expr)
+	if err != nil {
+		return
+	}
+
 	return expr.Accept(i)
 }
 
@@ -84,6 +176,7 @@ func (i *Interpreter) ensureNumber(operator *token.Token, operand ...interface{}
 			err = &RuntimeError{
 				Reason: fmt.Sprintf("operand(s) must be number(s), got %q(type %T)", item, item),
 				Token:  operator,
+				Code:   diag.CodeOperandType,
 			}
 			return
 		}
@@ -102,43 +195,49 @@ func (i *Interpreter) VisitBinaryExpr(v *ast.BinaryExpr) (result interface{}, er
 		return
 	}
 
-	switch v.Operator.Type {
+	return i.binary(v.Operator, left, right)
+}
+
+// binary applies operator on left and right,
+// compound assignments like "+=" work as their binary operators.
+func (i *Interpreter) binary(operator *token.Token, left, right interface{}) (result interface{}, err error) {
+	switch operator.Type {
 	case token.Greater:
-		err = i.ensureNumber(v.Operator, left, right)
+		err = i.ensureNumber(operator, left, right)
 		if err != nil {
 			return
 		}
 		result = left.(float64) > right.(float64)
 		return
 	case token.GreaterEqual:
-		err = i.ensureNumber(v.Operator, left, right)
+		err = i.ensureNumber(operator, left, right)
 		if err != nil {
 			return
 		}
 		result = left.(float64) >= right.(float64)
 		return
 	case token.Less:
-		err = i.ensureNumber(v.Operator, left, right)
+		err = i.ensureNumber(operator, left, right)
 		if err != nil {
 			return
 		}
 		result = left.(float64) < right.(float64)
 		return
 	case token.LessEqual:
-		err = i.ensureNumber(v.Operator, left, right)
+		err = i.ensureNumber(operator, left, right)
 		if err != nil {
 			return
 		}
 		result = left.(float64) <= right.(float64)
 		return
-	case token.Minus:
-		err = i.ensureNumber(v.Operator, left, right)
+	case token.Minus, token.MinusEqual:
+		err = i.ensureNumber(operator, left, right)
 		if err != nil {
 			return
 		}
 		result = left.(float64) - right.(float64)
 		return
-	case token.Plus:
+	case token.Plus, token.PlusEqual:
 		numLeft, okLeft := left.(float64)
 		numRight, okRight := right.(float64)
 		if okLeft && okRight {
@@ -150,16 +249,18 @@ func (i *Interpreter) VisitBinaryExpr(v *ast.BinaryExpr) (result interface{}, er
 		strRight, okRight := right.(string)
 		if okLeft && okRight {
 			result = strLeft + strRight
+			err = i.allocate(operator, sizeOf(result))
 			return
 		}
 
 		err = &RuntimeError{
 			Reason: fmt.Sprintf("operands must be both numbers or strings, got %v(%T) and %v(%T)", left, left, right, right),
-			Token:  v.Operator,
+			Token:  operator,
+			Code:   diag.CodeOperandType,
 		}
 		return
-	case token.Slash:
-		err = i.ensureNumber(v.Operator, left, right)
+	case token.Slash, token.SlashEqual:
+		err = i.ensureNumber(operator, left, right)
 		if err != nil {
 			return
 		}
@@ -171,19 +272,43 @@ func (i *Interpreter) VisitBinaryExpr(v *ast.BinaryExpr) (result interface{}, er
 
 			err = &RuntimeError{
 				Reason: "division by zero",
-				Token:  v.Operator,
+				Token:  operator,
+				Code:   diag.CodeDivisionByZero,
 			}
 			return
 		}
 		result = left.(float64) / right.(float64)
 		return
-	case token.Star:
-		err = i.ensureNumber(v.Operator, left, right)
+	case token.Star, token.StarEqual:
+		err = i.ensureNumber(operator, left, right)
 		if err != nil {
 			return
 		}
 		result = left.(float64) * right.(float64)
 		return
+	case token.Percent:
+		err = i.ensureNumber(operator, left, right)
+		if err != nil {
+			return
+		}
+		if right.(float64) == 0 {
+			err = &RuntimeError{
+				Reason: "modulo by zero",
+				Token:  operator,
+				Code:   diag.CodeDivisionByZero,
+			}
+			return
+		}
+		// the result has the sign of left
+		result = math.Mod(left.(float64), right.(float64))
+		return
+	case token.StarStar:
+		err = i.ensureNumber(operator, left, right)
+		if err != nil {
+			return
+		}
+		result = math.Pow(left.(float64), right.(float64))
+		return
 	case token.BangEqual:
 		result = !i.isEqual(left, right)
 		return
@@ -270,21 +395,98 @@ func (i *Interpreter) isEqual(a interface{}, b interface{}) bool {
 			return false
 		}
 		return ta == tb
+	case *GoObject:
+		// wrappers are made on each access, the struct is what matters
+		tb, ok := b.(*GoObject)
+		if !ok {
+			return false
+		}
+		// a struct and its first field share the address
+		return ta.value.Type() == tb.value.Type() && ta.value.Pointer() == tb.value.Pointer()
+	case *Instance, *Class, *Function, *List, *Map, *Namespace:
+		// objects are equal only to themselves
+		return a == b
+	case Callable:
+		// callables provided by the host application may not be comparable
+		if b == nil || !reflect.TypeOf(a).Comparable() || !reflect.TypeOf(b).Comparable() {
+			return false
+		}
+		return a == b
 	}
 
-	panic("isEqual: unreachable code, implementation error")
+	// values of unknown kinds are equal to nothing
+	return false
 }
 
 func (i *Interpreter) stringify(v interface{}) string {
-	if v == nil {
-		return "nil"
+	var b strings.Builder
+	i.writeValue(&b, v, nil)
+	return b.String()
+}
+
+// writeValue writes v in b as stringify does,
+// seen holds collections being written so that cycles end up as "[...]" or "{...}".
+func (i *Interpreter) writeValue(b *strings.Builder, v interface{}, seen map[interface{}]bool) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("nil")
+	case float64:
+		b.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	case *List:
+		if seen[v] {
+			b.WriteString("[...]")
+			return
+		}
+		if seen == nil {
+			seen = make(map[interface{}]bool)
+		}
+		seen[v] = true
+		defer delete(seen, v)
+
+		b.WriteByte('[')
+		for index, element := range v.Elements {
+			if index > 0 {
+				b.WriteString(", ")
+			}
+			i.writeElement(b, element, seen)
+		}
+		b.WriteByte(']')
+	case *Map:
+		if seen[v] {
+			b.WriteString("{...}")
+			return
+		}
+		if seen == nil {
+			seen = make(map[interface{}]bool)
+		}
+		seen[v] = true
+		defer delete(seen, v)
+
+		b.WriteByte('{')
+		for index, key := range v.keys {
+			if index > 0 {
+				b.WriteString(", ")
+			}
+			value, _ := v.Load(key)
+			i.writeElement(b, key, seen)
+			b.WriteString(": ")
+			i.writeElement(b, value, seen)
+		}
+		b.WriteByte('}')
+	default:
+		_, _ = fmt.Fprintf(b, "%v", v)
 	}
+}
 
-	if numV, ok := v.(float64); ok {
-		return strconv.FormatFloat(numV, 'f', -1, 64)
+// writeElement writes an element of a collection,
+// strings are quoted to tell "1" from 1.
+func (i *Interpreter) writeElement(b *strings.Builder, element interface{}, seen map[interface{}]bool) {
+	if s, ok := element.(string); ok {
+		b.WriteString(strconv.Quote(s))
+		return
 	}
 
-	return fmt.Sprintf("%v", v)
+	i.writeValue(b, element, seen)
 }
 
 func (i *Interpreter) VisitExprStmt(v *ast.ExprStmt) (err error) {
@@ -308,7 +510,12 @@ func (i *Interpreter) VisitPrintStmt(v *ast.PrintStmt) (err error) {
 	return
 }
 
-func (i *Interpreter) execute(stmt ast.Statement) error {
+func (i *Interpreter) execute(stmt ast.Statement) (err error) {
+	err = i.step(stmt)
+	if err != nil {
+		return
+	}
+
 	return stmt.Accept(i)
 }
 
@@ -337,18 +544,81 @@ func (i *Interpreter) VisitVariableExpr(v *ast.VariableExpr) (result interface{}
 }
 
 func (i *Interpreter) VisitAssignExpr(v *ast.AssignExpr) (result interface{}, err error) {
+	// the current value is read first for compound assignments
+	var current interface{}
+	if v.Operator != nil {
+		current, err = i.lookUpVariable(v.Name, v)
+		if err != nil {
+			return
+		}
+	}
+
 	result, err = i.evaluate(v.Value)
 	if err != nil {
 		return
 	}
 
+	if v.Operator != nil {
+		result, err = i.binary(v.Operator, current, result)
+		if err != nil {
+			return
+		}
+	}
+
 	distance, ok := i.locals[v]
 	if ok {
 		err = i.environment.AssignAt(distance, v.Name, result)
 	} else {
-		err = i.globals.Assign(v.Name, result)
+		err = i.environment.globals.Assign(v.Name, result)
+	}
+
+	return
+}
+
+func (i *Interpreter) VisitThrowStmt(v *ast.ThrowStmt) (err error) {
+	value, err := i.evaluate(v.Value)
+	if err != nil {
+		return
+	}
+
+	// rethrown errors keep their messages
+	reason, ok := errorMessage(value)
+	if !ok {
+		reason = "uncaught exception: " + i.stringify(value)
 	}
 
+	err = &RuntimeError{
+		Reason: reason,
+		Token:  v.Keyword,
+		Code:   diag.CodeUncaughtException,
+		Value:  value,
+	}
+	return
+}
+
+func (i *Interpreter) VisitTryStmt(v *ast.TryStmt) (err error) {
+	err = i.executeBlock(v.Body, NewChildEnvironment(i.environment))
+	if err != nil && v.CatchName != nil {
+		if value, ok := caught(err); ok {
+			env := NewChildEnvironment(i.environment)
+			env.Define(v.CatchName.Lexeme, value)
+			err = i.executeBlock(v.CatchBody, env)
+		}
+	}
+
+	if len(v.FinallyBody) == 0 {
+		return
+	}
+
+	// calls in finally must not overwrite the value being returned
+	returnValue := i.returnValue
+	finallyErr := i.executeBlock(v.FinallyBody, NewChildEnvironment(i.environment))
+	if finallyErr != nil {
+		// errors and jumps out of finally win
+		return finallyErr
+	}
+	i.returnValue = returnValue
+
 	return
 }
 
@@ -358,6 +628,11 @@ func (i *Interpreter) VisitBlockStmt(v *ast.BlockStmt) (err error) {
 }
 
 func (i *Interpreter) executeBlock(stmts []ast.Statement, blockEnv *Environment) (err error) {
+	err = i.cancelled()
+	if err != nil {
+		return
+	}
+
 	var previousEnv = i.environment
 	i.environment = blockEnv
 	defer func() {
@@ -394,16 +669,11 @@ func (i *Interpreter) VisitIfStmt(v *ast.IfStmt) (err error) {
 }
 
 func (i *Interpreter) VisitWhileStmt(v *ast.WhileStmt) (err error) {
-	done := i.ctx.Done()
-
 	var evalCondition interface{}
 	for {
-		select {
-		case <-done:
-			err = i.ctx.Err()
+		err = i.cancelled()
+		if err != nil {
 			return
-		default:
-			// relax
 		}
 
 		evalCondition, err = i.evaluate(v.Condition)
@@ -415,14 +685,43 @@ func (i *Interpreter) VisitWhileStmt(v *ast.WhileStmt) (err error) {
 		}
 
 		err = i.execute(v.Body)
+		if err == errBreak {
+			err = nil
+			break
+		}
+		if err == errContinue {
+			err = nil
+		}
 		if err != nil {
 			return
 		}
+
+		if v.Increment != nil {
+			_, err = i.evaluate(v.Increment)
+			if err != nil {
+				return
+			}
+		}
 	}
 
 	return
 }
 
+// errBreak and errContinue are sentinels like errReturn,
+// which unwind statements up to the innermost loop.
+var (
+	errBreak    = errors.New("break statement out of loop")
+	errContinue = errors.New("continue statement out of loop")
+)
+
+func (i *Interpreter) VisitBreakStmt(v *ast.BreakStmt) (err error) {
+	return errBreak
+}
+
+func (i *Interpreter) VisitContinueStmt(v *ast.ContinueStmt) (err error) {
+	return errContinue
+}
+
 func (i *Interpreter) VisitLogicalExpr(v *ast.LogicalExpr) (result interface{}, err error) {
 	result, err = i.evaluate(v.Left)
 	if err != nil {
@@ -450,18 +749,42 @@ func (i *Interpreter) VisitSetExpr(v *ast.SetExpr) (result interface{}, err erro
 		return
 	}
 
-	instance, ok := object.(*Instance)
+	holder, ok := object.(fieldHolder)
 	if !ok {
-		err = fmt.Errorf("only instances have fields, %T does not have field %q at line %d", object, v.Name.Lexeme, v.Name.Line)
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("only instances have fields, %T does not have field %q", object, v.Name.Lexeme),
+			Token:  v.Name,
+			Code:   diag.CodeNotInstance,
+		}
 		return
 	}
 
+	var current interface{}
+	if v.Operator != nil {
+		current, err = i.property(holder, v.Name, v.Span())
+		if err != nil {
+			return
+		}
+	}
+
 	result, err = i.evaluate(v.Value)
 	if err != nil {
 		return
 	}
-	instance.Set(v.Name, result)
 
+	if v.Operator != nil {
+		result, err = i.binary(v.Operator, current, result)
+		if err != nil {
+			return
+		}
+	}
+
+	err = i.allocate(v.Name, mapEntrySize)
+	if err != nil {
+		return
+	}
+
+	err = holder.SetField(v.Name, result)
 	return
 }
 
@@ -488,20 +811,84 @@ func (i *Interpreter) VisitCallExpr(v *ast.CallExpr) (result interface{}, err er
 
 	function, ok := callee.(Callable)
 	if !ok {
-		err = fmt.Errorf("can only call functuins and classes, got %T", callee)
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("can only call functions and classes, got %T", callee),
+			Token:  v.Paren,
+			Code:   diag.CodeNotCallable,
+		}
 		return
 	}
 	if want, got := function.Arity(), len(arguments); want != got {
-		err = fmt.Errorf("function expected %d arguments but got %d, at line %d", want, got, v.Paren.Line)
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("function expected %d arguments but got %d", want, got),
+			Token:  v.Paren,
+			Code:   diag.CodeArityMismatch,
+		}
+		return
+	}
+
+	return i.call(function, arguments, v.Paren, v.Span())
+}
+
+// call calls function with arguments, span covers the whole call.
+// Errors are reported on paren, which is the property name for getters.
+func (i *Interpreter) call(function Callable, arguments []interface{}, paren *token.Token, span token.Span) (result interface{}, err error) {
+	err = i.cancelled()
+	if err != nil {
+		return
+	}
+
+	frame, hasFrame := frameOf(function, span)
+	if hasFrame {
+		err = i.checkCallDepth(paren)
+		if err != nil {
+			return
+		}
+		i.pushFrame(frame)
+		defer i.popFrame()
+	}
+
+	// the environment of functions, or what natives may keep
+	size := len(arguments) * valueSize
+	if hasFrame {
+		size += environmentSize
+	}
+	if _, ok := function.(*Class); ok {
+		size += instanceSize
+	}
+	err = i.allocate(paren, size)
+	if err != nil {
 		return
 	}
 
 	result, err = function.Call(i, arguments)
 	if err != nil {
-		err = fmt.Errorf("calling function at line %d: %w", v.Paren.Line, err)
+		if isCancellation(err) {
+			return
+		}
+
+		var runtimeErr *RuntimeError
+		if !errors.As(err, &runtimeErr) {
+			// natives, or failures like writing to stdout, know nothing about
+			// where they happen, the call is the closest place known
+			runtimeErr = &RuntimeError{
+				Reason: err.Error(),
+				Token:  paren,
+				Err:    err,
+			}
+			err = runtimeErr
+		}
+		// the innermost call sees the error first
+		if runtimeErr.Trace == nil && hasFrame {
+			runtimeErr.Trace = i.stackTrace()
+		}
 		return
 	}
 
+	if !hasFrame {
+		err = i.allocate(paren, sizeOf(result))
+	}
+
 	return
 }
 
@@ -511,18 +898,192 @@ func (i *Interpreter) VisitGetExpr(v *ast.GetExpr) (result interface{}, err erro
 		return
 	}
 
-	instance, ok := object.(*Instance)
+	return i.property(object, v.Name, v.Span())
+}
+
+// property reads the property name of object, getters are called at once.
+func (i *Interpreter) property(object interface{}, name *token.Token, span token.Span) (result interface{}, err error) {
+	holder, ok := object.(propertyHolder)
 	if !ok {
-		err = fmt.Errorf("only instances have properties, %T does not have field %q at line %d", object, v.Name.Lexeme, v.Name.Line)
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("only instances and built-in types have properties, %T does not have field %q", object, name.Lexeme),
+			Token:  name,
+			Code:   diag.CodeNotInstance,
+		}
+		return
+	}
+
+	result, err = holder.Get(name)
+	if err != nil {
 		return
 	}
 
-	return instance.Get(v.Name)
+	if getter, ok := result.(*Function); ok && getter.IsGetter {
+		return i.call(getter, nil, name, span)
+	}
+
+	return
+}
+
+// propertyHolder is a value whose properties can be read by "."
+type propertyHolder interface {
+	Get(name *token.Token) (property interface{}, err error)
+}
+
+// fieldHolder is a value whose fields can be assigned by "."
+type fieldHolder interface {
+	propertyHolder
+	SetField(name *token.Token, value interface{}) (err error)
+}
+
+// indexable is a value whose elements can be accessed by "[]"
+type indexable interface {
+	GetIndex(bracket *token.Token, index interface{}) (element interface{}, err error)
+	SetIndex(bracket *token.Token, index interface{}, value interface{}) (err error)
+}
+
+func (i *Interpreter) VisitIndexGetExpr(v *ast.IndexGetExpr) (result interface{}, err error) {
+	object, err := i.evaluate(v.Object)
+	if err != nil {
+		return
+	}
+
+	index, err := i.evaluate(v.Index)
+	if err != nil {
+		return
+	}
+
+	container, ok := object.(indexable)
+	if !ok {
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("only lists and maps can be indexed, got %T", object),
+			Token:  v.Bracket,
+			Code:   diag.CodeOperandType,
+		}
+		return
+	}
+
+	return container.GetIndex(v.Bracket, index)
+}
+
+func (i *Interpreter) VisitIndexSetExpr(v *ast.IndexSetExpr) (result interface{}, err error) {
+	object, err := i.evaluate(v.Object)
+	if err != nil {
+		return
+	}
+
+	container, ok := object.(indexable)
+	if !ok {
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("only lists and maps can be indexed, got %T", object),
+			Token:  v.Bracket,
+			Code:   diag.CodeOperandType,
+		}
+		return
+	}
+
+	index, err := i.evaluate(v.Index)
+	if err != nil {
+		return
+	}
+
+	var current interface{}
+	if v.Operator != nil {
+		current, err = container.GetIndex(v.Bracket, index)
+		if err != nil {
+			return
+		}
+	}
+
+	result, err = i.evaluate(v.Value)
+	if err != nil {
+		return
+	}
+
+	if v.Operator != nil {
+		result, err = i.binary(v.Operator, current, result)
+		if err != nil {
+			return
+		}
+	}
+
+	err = i.allocate(v.Bracket, valueSize)
+	if err != nil {
+		return
+	}
+
+	err = container.SetIndex(v.Bracket, index, result)
+	return
+}
+
+func (i *Interpreter) VisitMapExpr(v *ast.MapExpr) (result interface{}, err error) {
+	m := NewMap()
+	for index := range v.Keys {
+		var key, value interface{}
+		key, err = i.evaluate(v.Keys[index])
+		if err != nil {
+			return
+		}
+		value, err = i.evaluate(v.Values[index])
+		if err != nil {
+			return
+		}
+
+		err = m.Store(key, value)
+		if err != nil {
+			err = &RuntimeError{
+				Reason: err.Error(),
+				Token:  v.Brace,
+				Code:   diag.CodeInvalidIndex,
+			}
+			return
+		}
+	}
+
+	result = m
+	err = i.allocate(v.Brace, sizeOf(m))
+	return
+}
+
+func (i *Interpreter) VisitInterpolationExpr(v *ast.InterpolationExpr) (result interface{}, err error) {
+	var b strings.Builder
+	for _, part := range v.Parts {
+		var value interface{}
+		value, err = i.evaluate(part)
+		if err != nil {
+			return
+		}
+
+		i.writeValue(&b, value, nil)
+	}
+
+	result = b.String()
+	err = i.allocate(spanToken(v.Span()), sizeOf(result))
+	return
+}
+
+func (i *Interpreter) VisitListExpr(v *ast.ListExpr) (result interface{}, err error) {
+	elements := make([]interface{}, 0, len(v.Elements))
+	for _, element := range v.Elements {
+		var value interface{}
+		value, err = i.evaluate(element)
+		if err != nil {
+			return
+		}
+
+		elements = append(elements, value)
+	}
+
+	result = NewList(elements)
+	err = i.allocate(spanToken(v.Span()), sizeOf(result))
+	return
 }
 
 func (i *Interpreter) VisitFunctionStmt(v *ast.FunctionStmt) (err error) {
 	i.environment.Define(v.Name.Lexeme, &Function{
-		Declaration:   v,
+		Name:          v.Name,
+		Params:        v.Params,
+		Body:          v.Body,
 		Closure:       i.environment,
 		IsInitializer: false,
 	})
@@ -530,6 +1091,29 @@ func (i *Interpreter) VisitFunctionStmt(v *ast.FunctionStmt) (err error) {
 	return nil
 }
 
+func (i *Interpreter) VisitConditionalExpr(v *ast.ConditionalExpr) (result interface{}, err error) {
+	condition, err := i.evaluate(v.Condition)
+	if err != nil {
+		return
+	}
+
+	if i.isTruthy(condition) {
+		return i.evaluate(v.ThenBranch)
+	}
+
+	return i.evaluate(v.ElseBranch)
+}
+
+func (i *Interpreter) VisitFunctionExpr(v *ast.FunctionExpr) (result interface{}, err error) {
+	result = &Function{
+		Params:        v.Params,
+		Body:          v.Body,
+		Closure:       i.environment,
+		IsInitializer: false,
+	}
+	return
+}
+
 func (i *Interpreter) VisitClassStmt(v *ast.ClassStmt) (err error) {
 	var superclass *Class
 	if v.SuperClass != nil {
@@ -542,7 +1126,11 @@ func (i *Interpreter) VisitClassStmt(v *ast.ClassStmt) (err error) {
 		var ok bool
 		superclass, ok = rawSuperclass.(*Class)
 		if !ok {
-			err = fmt.Errorf("superclass must be a class, got %T", rawSuperclass)
+			err = &RuntimeError{
+				Reason: fmt.Sprintf("superclass must be a class, got %T", rawSuperclass),
+				Token:  v.SuperClass.Name,
+				Code:   diag.CodeNotClass,
+			}
 			return
 		}
 	}
@@ -557,16 +1145,37 @@ func (i *Interpreter) VisitClassStmt(v *ast.ClassStmt) (err error) {
 	methods := make(map[string]*Function, len(v.Methods))
 	for _, method := range v.Methods {
 		methods[method.Name.Lexeme] = &Function{
-			Declaration:   method,
+			Name:          method.Name,
+			Params:        method.Params,
+			Body:          method.Body,
 			Closure:       i.environment,
 			IsInitializer: method.Name.Lexeme == "init",
+			IsGetter:      method.IsGetter,
+		}
+	}
+
+	staticMethods := make(map[string]*Function, len(v.StaticMethods))
+	for _, method := range v.StaticMethods {
+		staticMethods[method.Name.Lexeme] = &Function{
+			Name:     method.Name,
+			Params:   method.Params,
+			Body:     method.Body,
+			Closure:  i.environment,
+			IsGetter: method.IsGetter,
 		}
 	}
 
 	class := &Class{
-		Name:       v.Name.Lexeme,
-		SuperClass: superclass,
-		Methods:    methods,
+		Name:          v.Name.Lexeme,
+		SuperClass:    superclass,
+		Methods:       methods,
+		StaticMethods: staticMethods,
+	}
+	for _, method := range methods {
+		method.Class = class
+	}
+	for _, method := range staticMethods {
+		method.Class = class
 	}
 
 	if v.SuperClass != nil {
@@ -587,19 +1196,32 @@ func (i *Interpreter) VisitSuperExpr(v *ast.SuperExpr) (result interface{}, err
 	}
 	superClass := rawSuperclass.(*Class)
 
-	rawObject, err := i.environment.GetAt(distance-1, "this")
+	this, err := i.environment.GetAt(distance-1, "this")
 	if err != nil {
 		panic("no 'this' preceding super")
 	}
-	instance := rawObject.(*Instance)
-	method, ok := superClass.FindMethod(v.Method.Lexeme)
+
+	var method *Function
+	if _, inStatic := this.(*Class); inStatic {
+		method, ok = superClass.FindStaticMethod(v.Method.Lexeme)
+	} else {
+		method, ok = superClass.FindMethod(v.Method.Lexeme)
+	}
 	if !ok {
-		err = fmt.Errorf("super class does not have method %q: %s", v.Method.Lexeme, v.Method)
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("super class does not have method %q", v.Method.Lexeme),
+			Token:  v.Method,
+			Code:   diag.CodeUndefinedProperty,
+		}
 		return
 	}
 
-	result = method.Bind(instance)
+	method = method.Bind(this)
+	if method.IsGetter {
+		return i.call(method, nil, v.Method, v.Span())
+	}
 
+	result = method
 	return
 }
 
@@ -612,8 +1234,10 @@ func (i *Interpreter) VisitReturnStmt(v *ast.ReturnStmt) (err error) {
 		}
 	}
 
-	// unwind the stack
-	panic(&returnPayload{Value: value})
+	// unwind the stack, Function.Call picks the value up
+	i.returnValue = value
+	err = errReturn
+	return
 }
 
 func (i *Interpreter) lookUpVariable(name *token.Token, v ast.Expression) (result interface{}, err error) {
@@ -622,5 +1246,5 @@ func (i *Interpreter) lookUpVariable(name *token.Token, v ast.Expression) (resul
 		return i.environment.GetAt(distance, name.Lexeme)
 	}
 
-	return i.globals.Get(name)
+	return i.environment.globals.Get(name)
 }
@@ -1,6 +1,7 @@
 package interpreter
 
 import (
+	"fmt"
 	"math"
 	"reflect"
 	"testing"
@@ -120,4 +121,81 @@ func TestInterpreter_evaluate(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// uncomparableNative is a native which panics when compared by ==.
+type uncomparableNative []int
+
+func (uncomparableNative) Arity() int {
+	return 0
+}
+
+func (uncomparableNative) Call(*Interpreter, []interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func TestInterpreter_isEqual(t *testing.T) {
+	class := &Class{Name: "Cell", Methods: map[string]*Function{}}
+	otherClass := &Class{Name: "Cell", Methods: map[string]*Function{}}
+	instance := NewInstance(class)
+	otherInstance := NewInstance(class)
+	function := &Function{Name: &token.Token{Type: token.Identifier, Lexeme: "f"}}
+	otherFunction := &Function{Name: function.Name}
+	user := &bridgeUser{}
+
+	// one value of each kind, and all of them are different from each other
+	kinds := []struct {
+		name  string
+		value interface{}
+		// another value of the same kind which is not equal to value, optional
+		other interface{}
+	}{
+		{name: "nil", value: nil},
+		{name: "bool", value: true, other: false},
+		{name: "number", value: 1.0, other: 2.0},
+		{name: "string", value: "1", other: "2"},
+		{name: "instance", value: instance, other: otherInstance},
+		{name: "class", value: class, other: otherClass},
+		{name: "function", value: function, other: otherFunction},
+		{name: "native", value: nativeFuncClock{}, other: nativeFuncSleep{}},
+		{name: "list", value: NewList(nil), other: NewList(nil)},
+		{name: "map", value: NewMap(), other: NewMap()},
+		{name: "go object", value: &GoObject{value: reflect.ValueOf(user)}, other: &GoObject{value: reflect.ValueOf(&bridgeUser{})}},
+	}
+
+	i := NewInterpreter(nil)
+	for _, a := range kinds {
+		for _, b := range kinds {
+			t.Run(a.name+" == "+b.name, func(t *testing.T) {
+				if got, want := i.isEqual(a.value, b.value), a.name == b.name; got != want {
+					t.Errorf("isEqual() = %v, want %v", got, want)
+				}
+			})
+		}
+
+		if a.other != nil {
+			t.Run(a.name+" == another "+a.name, func(t *testing.T) {
+				if i.isEqual(a.value, a.other) || i.isEqual(a.other, a.value) {
+					t.Errorf("isEqual() = true, want false")
+				}
+			})
+		}
+	}
+
+	// never panic
+	for _, v := range []interface{}{uncomparableNative{1}, 1, struct{}{}} {
+		t.Run(fmt.Sprintf("%T", v), func(t *testing.T) {
+			for _, other := range append([]interface{}{v}, nativeFuncClock{}, nil, 1.0) {
+				if i.isEqual(v, other) || i.isEqual(other, v) {
+					t.Errorf("isEqual(%#v, %#v) = true, want false", v, other)
+				}
+			}
+		})
+	}
+
+	t.Run("go object == another wrapper of the same struct", func(t *testing.T) {
+		if !i.isEqual(&GoObject{value: reflect.ValueOf(user)}, &GoObject{value: reflect.ValueOf(user)}) {
+			t.Errorf("isEqual() = false, want true")
+		}
+	})
+}
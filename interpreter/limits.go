@@ -0,0 +1,168 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/nanmu42/bluelox/diag"
+	"github.com/nanmu42/bluelox/token"
+)
+
+// DefaultMaxCallDepth is used when Limits.MaxCallDepth is zero,
+// which is far below where the Go stack overflows.
+const DefaultMaxCallDepth = 10000
+
+// approximate sizes in bytes, for Limits.MaxMemory
+const (
+	valueSize       = 16
+	mapEntrySize    = 3 * valueSize
+	instanceSize    = 64
+	environmentSize = 64
+)
+
+// Limits bounds what a run can take, zero means no limit.
+//
+// Usage is counted from the start of Interpret,
+// or Call from the host application, to its end,
+// runs and calls nested in natives count in the outer one.
+type Limits struct {
+	// MaxSteps is how many statements and expressions can be executed.
+	MaxSteps int
+	// MaxCallDepth is how deep calls of Lox functions can nest,
+	// DefaultMaxCallDepth is used when it's zero since the Go stack is never unlimited.
+	MaxCallDepth int
+	// MaxMemory is approximately how many bytes can be allocated for
+	// strings, lists, maps, instances and environments of calls.
+	// Memory freed during the run is not given back.
+	MaxMemory int
+}
+
+// StepLimitError is the cause of the RuntimeError when Limits.MaxSteps is exceeded.
+type StepLimitError struct {
+	Limit int
+}
+
+func (e *StepLimitError) Error() string {
+	return fmt.Sprintf("exceeded the limit of %d steps", e.Limit)
+}
+
+// CallDepthError is the cause of the RuntimeError when Limits.MaxCallDepth is exceeded.
+type CallDepthError struct {
+	Limit int
+}
+
+func (e *CallDepthError) Error() string {
+	return fmt.Sprintf("exceeded the limit of %d nested calls", e.Limit)
+}
+
+// MemoryLimitError is the cause of the RuntimeError when Limits.MaxMemory is exceeded.
+type MemoryLimitError struct {
+	Limit int
+}
+
+func (e *MemoryLimitError) Error() string {
+	return fmt.Sprintf("exceeded the limit of %d bytes of memory", e.Limit)
+}
+
+// SetLimits replaces limits of runs after.
+func (i *Interpreter) SetLimits(limits Limits) {
+	if limits.MaxCallDepth == 0 {
+		limits.MaxCallDepth = DefaultMaxCallDepth
+	}
+
+	i.limits = limits
+}
+
+// resetUsage starts counting for a new run.
+func (i *Interpreter) resetUsage() {
+	i.steps = 0
+	i.memory = 0
+}
+
+// step counts a statement or an expression at span.
+func (i *Interpreter) step(node interface{ Span() token.Span }) (err error) {
+	i.steps++
+	if i.limits.MaxSteps > 0 && i.steps > i.limits.MaxSteps {
+		cause := &StepLimitError{Limit: i.limits.MaxSteps}
+		err = &RuntimeError{
+			Reason: cause.Error(),
+			Token:  spanToken(node.Span()),
+			Code:   diag.CodeStepLimit,
+			Err:    cause,
+		}
+		return
+	}
+
+	return
+}
+
+// checkCallDepth reports an error if one more call nests too deep.
+func (i *Interpreter) checkCallDepth(paren *token.Token) (err error) {
+	if len(i.frames) < i.limits.MaxCallDepth {
+		return
+	}
+
+	cause := &CallDepthError{Limit: i.limits.MaxCallDepth}
+	err = &RuntimeError{
+		Reason: cause.Error(),
+		Token:  paren,
+		Code:   diag.CodeCallDepthLimit,
+		Err:    cause,
+	}
+	return
+}
+
+// allocate counts size bytes allocated at where.
+func (i *Interpreter) allocate(where *token.Token, size int) (err error) {
+	i.memory += size
+	if i.limits.MaxMemory > 0 && i.memory > i.limits.MaxMemory {
+		cause := &MemoryLimitError{Limit: i.limits.MaxMemory}
+		err = &RuntimeError{
+			Reason: cause.Error(),
+			Token:  where,
+			Code:   diag.CodeMemoryLimit,
+			Err:    cause,
+		}
+		return
+	}
+
+	return
+}
+
+// sizeOf approximates bytes taken by value, elements of lists and maps are not followed.
+// Values which are not allocated, like numbers, take nothing.
+func sizeOf(value interface{}) int {
+	switch value := value.(type) {
+	case string:
+		return valueSize + len(value)
+	case *List:
+		return valueSize + len(value.Elements)*valueSize
+	case *Map:
+		return valueSize + value.Len()*mapEntrySize
+	case *Instance:
+		return instanceSize + len(value.fields)*mapEntrySize
+	}
+
+	return 0
+}
+
+// isLimitError reports whether err is caused by exceeding Limits,
+// which scripts can not catch.
+func isLimitError(r *RuntimeError) bool {
+	switch r.Code {
+	case diag.CodeStepLimit, diag.CodeCallDepthLimit, diag.CodeMemoryLimit:
+		return true
+	}
+
+	return false
+}
+
+// spanToken returns a token pointing at span, for errors without a token at hand.
+func spanToken(span token.Span) *token.Token {
+	return &token.Token{
+		Offset: span.Offset,
+		End:    span.End,
+		Line:   span.Line,
+		Column: span.Column,
+		File:   span.File,
+	}
+}
@@ -0,0 +1,139 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/nanmu42/bluelox/diag"
+	"github.com/nanmu42/bluelox/token"
+)
+
+// List is a mutable sequence of Lox values.
+type List struct {
+	Elements []interface{}
+}
+
+func NewList(elements []interface{}) *List {
+	return &List{Elements: elements}
+}
+
+// Get returns built-in methods of the list:
+//
+//	length() returns how many elements there are
+//	push(value) appends value to the end
+//	pop() removes the last element and returns it
+//	slice(start, end) returns a new list of elements in [start, end)
+func (l *List) Get(name *token.Token) (property interface{}, err error) {
+	switch name.Lexeme {
+	case "length":
+		property = &nativeMethod{arity: 0, fn: func(arguments []interface{}) (interface{}, error) {
+			return float64(len(l.Elements)), nil
+		}}
+	case "push":
+		property = &nativeMethod{arity: 1, fn: func(arguments []interface{}) (interface{}, error) {
+			l.Elements = append(l.Elements, arguments[0])
+			return nil, nil
+		}}
+	case "pop":
+		property = &nativeMethod{arity: 0, fn: l.pop}
+	case "slice":
+		property = &nativeMethod{arity: 2, fn: l.slice}
+	default:
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("undefined property %q of list", name.Lexeme),
+			Token:  name,
+			Code:   diag.CodeUndefinedProperty,
+		}
+	}
+
+	return
+}
+
+func (l *List) pop(arguments []interface{}) (result interface{}, err error) {
+	length := len(l.Elements)
+	if length == 0 {
+		err = errors.New("pop from empty list")
+		return
+	}
+
+	result = l.Elements[length-1]
+	l.Elements[length-1] = nil
+	l.Elements = l.Elements[:length-1]
+	return
+}
+
+func (l *List) slice(arguments []interface{}) (result interface{}, err error) {
+	start, ok := toInteger(arguments[0])
+	if !ok {
+		err = fmt.Errorf("slice start must be an integer, got %v(%T)", arguments[0], arguments[0])
+		return
+	}
+	end, ok := toInteger(arguments[1])
+	if !ok {
+		err = fmt.Errorf("slice end must be an integer, got %v(%T)", arguments[1], arguments[1])
+		return
+	}
+	if start < 0 || end < start || end > len(l.Elements) {
+		err = fmt.Errorf("slice bounds [%d, %d) out of range with length %d", start, end, len(l.Elements))
+		return
+	}
+
+	elements := make([]interface{}, end-start)
+	copy(elements, l.Elements[start:end])
+	return NewList(elements), nil
+}
+
+// GetIndex returns the element at index.
+func (l *List) GetIndex(bracket *token.Token, index interface{}) (element interface{}, err error) {
+	i, err := l.checkIndex(bracket, index)
+	if err != nil {
+		return
+	}
+
+	return l.Elements[i], nil
+}
+
+// SetIndex replaces the element at index with value.
+func (l *List) SetIndex(bracket *token.Token, index interface{}, value interface{}) (err error) {
+	i, err := l.checkIndex(bracket, index)
+	if err != nil {
+		return
+	}
+
+	l.Elements[i] = value
+	return
+}
+
+func (l *List) checkIndex(bracket *token.Token, index interface{}) (i int, err error) {
+	i, ok := toInteger(index)
+	if !ok {
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("list index must be an integer, got %v(%T)", index, index),
+			Token:  bracket,
+			Code:   diag.CodeInvalidIndex,
+		}
+		return
+	}
+
+	if i < 0 || i >= len(l.Elements) {
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("list index %d out of range with length %d", i, len(l.Elements)),
+			Token:  bracket,
+			Code:   diag.CodeIndexOutOfRange,
+		}
+		return
+	}
+
+	return
+}
+
+// toInteger converts v into an int if it's a number without fraction.
+func toInteger(v interface{}) (i int, ok bool) {
+	number, ok := v.(float64)
+	if !ok || math.Trunc(number) != number || math.IsInf(number, 0) {
+		return 0, false
+	}
+
+	return int(number), true
+}
@@ -0,0 +1,204 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/nanmu42/bluelox/diag"
+	"github.com/nanmu42/bluelox/token"
+)
+
+// Map is a mutable Lox map, whose keys are strings, numbers, booleans or nil.
+//
+// Keys are equal when isEqual says so, and they are kept in insertion order,
+// so that printing and iterating is deterministic.
+type Map struct {
+	// keys in insertion order
+	keys   []interface{}
+	values map[interface{}]interface{}
+}
+
+func NewMap() *Map {
+	return &Map{
+		values: make(map[interface{}]interface{}),
+	}
+}
+
+// nanKey stands for NaN in Go maps, since NaN != NaN in Go but NaN == NaN in Lox.
+type nanKey struct{}
+
+// hashKey checks key and converts it into a Go map key.
+func hashKey(key interface{}) (hashed interface{}, ok bool) {
+	switch key := key.(type) {
+	case nil, bool, string:
+		return key, true
+	case float64:
+		if math.IsNaN(key) {
+			return nanKey{}, true
+		}
+		// -0 and 0 are the same key in Go
+		return key, true
+	}
+
+	return nil, false
+}
+
+// keyString returns key in the form of Lox source.
+func keyString(key interface{}) string {
+	switch key := key.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(key)
+	case float64:
+		return strconv.FormatFloat(key, 'f', -1, 64)
+	}
+
+	return fmt.Sprintf("%v", key)
+}
+
+// Len returns how many entries there are.
+func (m *Map) Len() int {
+	return len(m.keys)
+}
+
+// Keys returns keys in insertion order.
+func (m *Map) Keys() []interface{} {
+	keys := make([]interface{}, len(m.keys))
+	copy(keys, m.keys)
+	return keys
+}
+
+// Load returns the value of key, ok is false if there is no such key or key is not valid.
+func (m *Map) Load(key interface{}) (value interface{}, ok bool) {
+	hashed, ok := hashKey(key)
+	if !ok {
+		return
+	}
+
+	value, ok = m.values[hashed]
+	return
+}
+
+// Store sets the value of key, which must be valid.
+func (m *Map) Store(key interface{}, value interface{}) (err error) {
+	hashed, ok := hashKey(key)
+	if !ok {
+		err = fmt.Errorf("map key must be a string, number, boolean or nil, got %T", key)
+		return
+	}
+
+	if _, existed := m.values[hashed]; !existed {
+		m.keys = append(m.keys, key)
+	}
+	m.values[hashed] = value
+	return
+}
+
+// Delete removes key, and reports whether it existed.
+func (m *Map) Delete(key interface{}) (existed bool) {
+	hashed, ok := hashKey(key)
+	if !ok {
+		return false
+	}
+
+	if _, existed = m.values[hashed]; !existed {
+		return
+	}
+
+	delete(m.values, hashed)
+	for index, item := range m.keys {
+		if item, _ := hashKey(item); item == hashed {
+			m.keys = append(m.keys[:index], m.keys[index+1:]...)
+			break
+		}
+	}
+
+	return
+}
+
+// Get returns built-in methods of the map:
+//
+//	length() returns how many entries there are
+//	has(key) reports whether key exists
+//	delete(key) removes key and reports whether it existed
+//	keys() returns a list of keys in insertion order
+//	values() returns a list of values in insertion order of keys
+func (m *Map) Get(name *token.Token) (property interface{}, err error) {
+	switch name.Lexeme {
+	case "length":
+		property = &nativeMethod{arity: 0, fn: func(arguments []interface{}) (interface{}, error) {
+			return float64(m.Len()), nil
+		}}
+	case "has":
+		property = &nativeMethod{arity: 1, fn: func(arguments []interface{}) (interface{}, error) {
+			_, ok := m.Load(arguments[0])
+			return ok, nil
+		}}
+	case "delete":
+		property = &nativeMethod{arity: 1, fn: func(arguments []interface{}) (interface{}, error) {
+			return m.Delete(arguments[0]), nil
+		}}
+	case "keys":
+		property = &nativeMethod{arity: 0, fn: func(arguments []interface{}) (interface{}, error) {
+			return NewList(m.Keys()), nil
+		}}
+	case "values":
+		property = &nativeMethod{arity: 0, fn: func(arguments []interface{}) (interface{}, error) {
+			values := make([]interface{}, 0, len(m.keys))
+			for _, key := range m.keys {
+				value, _ := m.Load(key)
+				values = append(values, value)
+			}
+			return NewList(values), nil
+		}}
+	default:
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("undefined property %q of map", name.Lexeme),
+			Token:  name,
+			Code:   diag.CodeUndefinedProperty,
+		}
+	}
+
+	return
+}
+
+// GetIndex returns the value of key.
+func (m *Map) GetIndex(bracket *token.Token, key interface{}) (value interface{}, err error) {
+	if _, ok := hashKey(key); !ok {
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("map key must be a string, number, boolean or nil, got %T", key),
+			Token:  bracket,
+			Code:   diag.CodeInvalidIndex,
+		}
+		return
+	}
+
+	value, ok := m.Load(key)
+	if !ok {
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("key %s not found in map", keyString(key)),
+			Token:  bracket,
+			Code:   diag.CodeKeyNotFound,
+		}
+		return
+	}
+
+	return
+}
+
+// SetIndex sets the value of key.
+func (m *Map) SetIndex(bracket *token.Token, key interface{}, value interface{}) (err error) {
+	err = m.Store(key, value)
+	if err != nil {
+		err = &RuntimeError{
+			Reason: err.Error(),
+			Token:  bracket,
+			Code:   diag.CodeInvalidIndex,
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,262 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/nanmu42/bluelox/ast"
+	"github.com/nanmu42/bluelox/diag"
+)
+
+// ModuleLoader reads, parses and resolves the module at path for the interpreter,
+// path is absolute.
+type ModuleLoader func(path string) (stmts []ast.Statement, err error)
+
+// module is a Lox source file with its own global environment.
+type module struct {
+	// absolute path of the file, empty for scripts not from files
+	path    string
+	globals *Environment
+	// exported names in declaration order
+	exports []string
+	// the module which imports this one first, nil for the script
+	importer *module
+	// whether the module has been evaluated,
+	// a module being evaluated and imported again means an import cycle.
+	done bool
+}
+
+func newModule(path string, globals *Environment, importer *module) *module {
+	return &module{
+		path:     path,
+		globals:  globals,
+		importer: importer,
+	}
+}
+
+func (m *module) export(name string) {
+	for _, exported := range m.exports {
+		if exported == name {
+			return
+		}
+	}
+
+	m.exports = append(m.exports, name)
+}
+
+func (m *module) isExported(name string) bool {
+	for _, exported := range m.exports {
+		if exported == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetModuleLoader sets how imported modules are loaded,
+// import statements fail if there is no loader.
+func (i *Interpreter) SetModuleLoader(loader ModuleLoader) {
+	i.loader = loader
+}
+
+// SetScriptPath tells where the script being interpreted comes from,
+// imports are resolved relative to it instead of the working directory.
+func (i *Interpreter) SetScriptPath(path string) (err error) {
+	path, err = filepath.Abs(path)
+	if err != nil {
+		err = fmt.Errorf("resolving script path: %w", err)
+		return
+	}
+
+	i.module.path = path
+	i.modules[path] = i.module
+	return
+}
+
+func (i *Interpreter) VisitExportStmt(v *ast.ExportStmt) (err error) {
+	err = i.execute(v.Decl)
+	if err != nil {
+		return
+	}
+
+	switch decl := v.Decl.(type) {
+	case *ast.ClassStmt:
+		i.module.export(decl.Name.Lexeme)
+	case *ast.FunctionStmt:
+		i.module.export(decl.Name.Lexeme)
+	case *ast.VarStmt:
+		i.module.export(decl.Name.Lexeme)
+	default:
+		err = fmt.Errorf("exporting %T: unreachable code, implementation error", v.Decl)
+	}
+
+	return
+}
+
+// VisitImportStmt defines exported names of the module in the importer.
+//
+// Values are copied when import runs, like "var name = value;",
+// so reassigning an exported variable later in the module,
+// even inside an exported function, is not seen by importers.
+// Changes of lists, maps and instances are seen since they are shared.
+func (i *Interpreter) VisitImportStmt(v *ast.ImportStmt) (err error) {
+	imported, err := i.importModule(v)
+	if err != nil {
+		return
+	}
+
+	if v.Names == nil {
+		for _, name := range imported.exports {
+			i.environment.Define(name, imported.globals.values[name])
+		}
+		return
+	}
+
+	for _, name := range v.Names {
+		if !imported.isExported(name.Lexeme) {
+			err = &RuntimeError{
+				Reason: fmt.Sprintf("%q is not exported by module %s", name.Lexeme, v.Path.Lexeme),
+				Token:  name,
+				Code:   diag.CodeNotExported,
+			}
+			return
+		}
+
+		i.environment.Define(name.Lexeme, imported.globals.values[name.Lexeme])
+	}
+
+	return
+}
+
+// importModule returns the module imported by v,
+// which is evaluated at the first import and cached.
+func (i *Interpreter) importModule(v *ast.ImportStmt) (imported *module, err error) {
+	path := v.Path.Literal.(string)
+	if !filepath.IsAbs(path) {
+		dir := "."
+		if i.module.path != "" {
+			dir = filepath.Dir(i.module.path)
+		}
+		path = filepath.Join(dir, path)
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("resolving module path: %s", err),
+			Token:  v.Path,
+			Code:   diag.CodeImport,
+			Err:    err,
+		}
+		return
+	}
+
+	imported, ok := i.modules[path]
+	if ok {
+		if !imported.done {
+			err = &RuntimeError{
+				Reason: fmt.Sprintf("import cycle: %s", i.importChain(path)),
+				Token:  v.Path,
+				Code:   diag.CodeImportCycle,
+			}
+			return
+		}
+
+		return
+	}
+
+	if i.loader == nil {
+		err = &RuntimeError{
+			Reason: "importing is not supported here",
+			Token:  v.Keyword,
+			Code:   diag.CodeImport,
+		}
+		return
+	}
+
+	stmts, err := i.loader(path)
+	if err != nil {
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("loading module %s: %s", v.Path.Lexeme, err),
+			Token:  v.Path,
+			Code:   diag.CodeImport,
+			Err:    err,
+		}
+		return
+	}
+
+	imported = newModule(path, i.newGlobalEnvironment(), i.module)
+	i.modules[path] = imported
+
+	err = i.executeModule(imported, stmts)
+	if err != nil {
+		// the module can be imported again after it's fixed
+		delete(i.modules, path)
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("evaluating module %s: %s", v.Path.Lexeme, describeModuleError(path, err)),
+			Token:  v.Path,
+			Code:   diag.CodeImport,
+			Err:    err,
+		}
+		return
+	}
+
+	imported.done = true
+	return
+}
+
+// executeModule executes stmts of m in its own global environment.
+func (i *Interpreter) executeModule(m *module, stmts []ast.Statement) (err error) {
+	previousEnv, previousModule := i.environment, i.module
+	i.environment, i.module = m.globals, m
+	defer func() {
+		i.environment, i.module = previousEnv, previousModule
+	}()
+
+	for _, stmt := range stmts {
+		err = i.execute(stmt)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// describeModuleError describes err out of the module at path,
+// with where it happens in the module.
+func describeModuleError(path string, err error) string {
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		return err.Error()
+	}
+
+	switch {
+	case runtimeErr.Code == diag.CodeImport, runtimeErr.Code == diag.CodeImportCycle:
+		// already described by the nested import
+		return runtimeErr.Reason
+	case runtimeErr.Token != nil:
+		return fmt.Sprintf("%s:%s: %s", filepath.Base(path), runtimeErr.Token.Span(), runtimeErr.Reason)
+	}
+
+	return runtimeErr.Reason
+}
+
+// importChain describes how path is imported again, like "a.lox -> b.lox -> a.lox".
+func (i *Interpreter) importChain(path string) string {
+	chain := []string{filepath.Base(path)}
+	for m := i.module; m != nil; m = m.importer {
+		chain = append(chain, filepath.Base(m.path))
+		if m.path == path {
+			break
+		}
+	}
+
+	for left, right := 0, len(chain)-1; left < right; left, right = left+1, right-1 {
+		chain[left], chain[right] = chain[right], chain[left]
+	}
+
+	return strings.Join(chain, " -> ")
+}
@@ -0,0 +1,348 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nanmu42/bluelox/diag"
+	"github.com/nanmu42/bluelox/token"
+)
+
+// Namespace groups natives by what they work on, like Math.sqrt.
+type Namespace struct {
+	Name    string
+	members map[string]interface{}
+}
+
+func (n *Namespace) String() string {
+	return fmt.Sprintf("<namespace %s>", n.Name)
+}
+
+// Get returns the member of the namespace.
+func (n *Namespace) Get(name *token.Token) (property interface{}, err error) {
+	property, ok := n.members[name.Lexeme]
+	if !ok {
+		err = &RuntimeError{
+			Reason: fmt.Sprintf("undefined property %q of namespace %s", name.Lexeme, n.Name),
+			Token:  name,
+			Code:   diag.CodeUndefinedProperty,
+		}
+		return
+	}
+
+	return
+}
+
+// nativeFunc is a native function which needs the interpreter, like str().
+type nativeFunc struct {
+	arity int
+	fn    func(interpreter *Interpreter, arguments []interface{}) (result interface{}, err error)
+}
+
+func (n *nativeFunc) Arity() int {
+	return n.arity
+}
+
+func (n *nativeFunc) Call(interpreter *Interpreter, arguments []interface{}) (result interface{}, err error) {
+	return n.fn(interpreter, arguments)
+}
+
+func (n *nativeFunc) String() string {
+	return nativeFuncStringForm
+}
+
+// pure returns a native function which needs nothing but arguments.
+func pure(arity int, fn func(arguments []interface{}) (result interface{}, err error)) *nativeFunc {
+	return &nativeFunc{
+		arity: arity,
+		fn: func(_ *Interpreter, arguments []interface{}) (interface{}, error) {
+			return fn(arguments)
+		},
+	}
+}
+
+// stdlib is defined in every global environment,
+// natives are stateless so that they can be shared.
+var stdlib = map[string]interface{}{
+	"clock": nativeFuncClock{},
+	"sleep": nativeFuncSleep{},
+	"randN": nativeFuncRandN{},
+
+	"type":  pure(1, typeOf),
+	"str":   &nativeFunc{arity: 1, fn: str},
+	"num":   pure(1, num),
+	"parse": pure(1, parse),
+
+	"Math": &Namespace{
+		Name: "Math",
+		members: map[string]interface{}{
+			"floor": mathFunc1("floor", math.Floor),
+			"sqrt":  mathFunc1("sqrt", math.Sqrt),
+			"abs":   mathFunc1("abs", math.Abs),
+			"pow":   mathFunc2("pow", math.Pow),
+			"min":   mathFunc2("min", math.Min),
+			"max":   mathFunc2("max", math.Max),
+		},
+	},
+	"String": &Namespace{
+		Name: "String",
+		members: map[string]interface{}{
+			"len":     pure(1, stringLen),
+			"substr":  pure(3, substr),
+			"upper":   stringFunc1("upper", strings.ToUpper),
+			"lower":   stringFunc1("lower", strings.ToLower),
+			"split":   pure(2, split),
+			"indexOf": pure(2, indexOf),
+		},
+	},
+	"IO": &Namespace{
+		Name: "IO",
+		members: map[string]interface{}{
+			"readLine": &nativeFunc{arity: 0, fn: readLine},
+			"readFile": &nativeFunc{arity: 1, fn: readFile},
+		},
+	},
+}
+
+// typeOf returns the type name of the value.
+func typeOf(arguments []interface{}) (result interface{}, err error) {
+	name, ok := typeName(arguments[0])
+	if !ok {
+		err = fmt.Errorf("type() of unexpected %T, implementation error", arguments[0])
+		return
+	}
+
+	return name, nil
+}
+
+func typeName(v interface{}) (name string, ok bool) {
+	switch v.(type) {
+	case nil:
+		return "nil", true
+	case bool:
+		return "boolean", true
+	case float64:
+		return "number", true
+	case string:
+		return "string", true
+	case *List:
+		return "list", true
+	case *Map:
+		return "map", true
+	case *Class:
+		return "class", true
+	case *Instance, *GoObject:
+		return "instance", true
+	case *Namespace:
+		return "namespace", true
+	case Callable:
+		return "function", true
+	}
+
+	return fmt.Sprintf("%T", v), false
+}
+
+// str returns the value in string as print does.
+func str(interpreter *Interpreter, arguments []interface{}) (result interface{}, err error) {
+	return interpreter.stringify(arguments[0]), nil
+}
+
+// num converts numbers, booleans and strings of numbers into numbers.
+func num(arguments []interface{}) (result interface{}, err error) {
+	switch value := arguments[0].(type) {
+	case float64:
+		return value, nil
+	case bool:
+		if value {
+			return float64(1), nil
+		}
+		return float64(0), nil
+	case string:
+		number, ok := parseNumber(value)
+		if !ok {
+			err = fmt.Errorf("num() can not convert %q into a number", value)
+			return
+		}
+		return number, nil
+	}
+
+	err = fmt.Errorf("num() can not convert %T into a number", arguments[0])
+	return
+}
+
+// parse returns the number in the string, or nil if there is none.
+func parse(arguments []interface{}) (result interface{}, err error) {
+	s, ok := arguments[0].(string)
+	if !ok {
+		err = fmt.Errorf("parse() requires a string, not %T", arguments[0])
+		return
+	}
+
+	number, ok := parseNumber(s)
+	if !ok {
+		return nil, nil
+	}
+
+	return number, nil
+}
+
+func parseNumber(s string) (number float64, ok bool) {
+	number, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return number, err == nil
+}
+
+func mathFunc1(name string, fn func(x float64) float64) *nativeFunc {
+	return pure(1, func(arguments []interface{}) (result interface{}, err error) {
+		x, ok := arguments[0].(float64)
+		if !ok {
+			err = fmt.Errorf("%s() requires a number, not %T", name, arguments[0])
+			return
+		}
+
+		return fn(x), nil
+	})
+}
+
+func mathFunc2(name string, fn func(x, y float64) float64) *nativeFunc {
+	return pure(2, func(arguments []interface{}) (result interface{}, err error) {
+		x, okX := arguments[0].(float64)
+		y, okY := arguments[1].(float64)
+		if !okX || !okY {
+			err = fmt.Errorf("%s() requires two numbers, not %T and %T", name, arguments[0], arguments[1])
+			return
+		}
+
+		return fn(x, y), nil
+	})
+}
+
+func stringFunc1(name string, fn func(s string) string) *nativeFunc {
+	return pure(1, func(arguments []interface{}) (result interface{}, err error) {
+		s, ok := arguments[0].(string)
+		if !ok {
+			err = fmt.Errorf("%s() requires a string, not %T", name, arguments[0])
+			return
+		}
+
+		return fn(s), nil
+	})
+}
+
+// stringLen returns how many characters, instead of bytes, are there in the string.
+func stringLen(arguments []interface{}) (result interface{}, err error) {
+	s, ok := arguments[0].(string)
+	if !ok {
+		err = fmt.Errorf("len() requires a string, not %T", arguments[0])
+		return
+	}
+
+	return float64(utf8.RuneCountInString(s)), nil
+}
+
+// substr returns characters in [start, end) of the string.
+func substr(arguments []interface{}) (result interface{}, err error) {
+	s, ok := arguments[0].(string)
+	if !ok {
+		err = fmt.Errorf("substr() requires a string, not %T", arguments[0])
+		return
+	}
+	start, okStart := toInteger(arguments[1])
+	end, okEnd := toInteger(arguments[2])
+	if !okStart || !okEnd {
+		err = fmt.Errorf("substr() requires integer bounds, got %v and %v", arguments[1], arguments[2])
+		return
+	}
+
+	runes := []rune(s)
+	if start < 0 || end < start || end > len(runes) {
+		err = fmt.Errorf("substr() bounds [%d, %d) out of range with length %d", start, end, len(runes))
+		return
+	}
+
+	return string(runes[start:end]), nil
+}
+
+// split slices the string into a list of substrings separated by sep.
+func split(arguments []interface{}) (result interface{}, err error) {
+	s, okS := arguments[0].(string)
+	sep, okSep := arguments[1].(string)
+	if !okS || !okSep {
+		err = fmt.Errorf("split() requires two strings, not %T and %T", arguments[0], arguments[1])
+		return
+	}
+
+	parts := strings.Split(s, sep)
+	elements := make([]interface{}, 0, len(parts))
+	for _, part := range parts {
+		elements = append(elements, part)
+	}
+
+	return NewList(elements), nil
+}
+
+// indexOf returns the character index of the first sub in the string, or -1 if there is none.
+func indexOf(arguments []interface{}) (result interface{}, err error) {
+	s, okS := arguments[0].(string)
+	sub, okSub := arguments[1].(string)
+	if !okS || !okSub {
+		err = fmt.Errorf("indexOf() requires two strings, not %T and %T", arguments[0], arguments[1])
+		return
+	}
+
+	index := strings.Index(s, sub)
+	if index < 0 {
+		return float64(-1), nil
+	}
+
+	return float64(utf8.RuneCountInString(s[:index])), nil
+}
+
+// readLine reads a line from stdin without the line break, nil at the end of input.
+func readLine(interpreter *Interpreter, _ []interface{}) (result interface{}, err error) {
+	if interpreter.stdin == nil {
+		err = errors.New("readLine(): reading input is not allowed here")
+		return
+	}
+
+	line, err := interpreter.stdin.ReadString('\n')
+	if errors.Is(err, io.EOF) {
+		if line == "" {
+			return nil, nil
+		}
+		err = nil
+	}
+	if err != nil {
+		err = fmt.Errorf("readLine(): %w", err)
+		return
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return line, nil
+}
+
+// readFile returns the content of the file at path.
+func readFile(interpreter *Interpreter, arguments []interface{}) (result interface{}, err error) {
+	path, ok := arguments[0].(string)
+	if !ok {
+		err = fmt.Errorf("readFile() requires a path in string, not %T", arguments[0])
+		return
+	}
+	if interpreter.fileReader == nil {
+		err = errors.New("readFile(): reading files is not allowed here")
+		return
+	}
+
+	content, err := interpreter.fileReader(path)
+	if err != nil {
+		err = fmt.Errorf("readFile(): %w", err)
+		return
+	}
+
+	return string(content), nil
+}
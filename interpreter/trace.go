@@ -0,0 +1,132 @@
+package interpreter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nanmu42/bluelox/token"
+)
+
+// Frame is a Lox function call on the call stack.
+type Frame struct {
+	// Function name of the function
+	Function string
+	// Class which the method belongs to, empty for functions
+	Class string
+	// Call is where the function is called
+	Call token.Span
+}
+
+func (f Frame) String() string {
+	if f.Class != "" {
+		return f.Class + "." + f.Function
+	}
+
+	return f.Function
+}
+
+// StackTrace is the call stack when a runtime error happens,
+// the outermost call comes first.
+type StackTrace []Frame
+
+// frameOf returns the frame for calling callee at call,
+// ok is false for natives which has no frame.
+func frameOf(callee Callable, call token.Span) (frame Frame, ok bool) {
+	switch callee := callee.(type) {
+	case *Function:
+		frame = Frame{
+			Function: "<anonymous>",
+			Call:     call,
+		}
+		if callee.Name != nil {
+			frame.Function = callee.Name.Lexeme
+		}
+		if callee.Class != nil {
+			frame.Class = callee.Class.Name
+		}
+		return frame, true
+	case *Class:
+		return Frame{
+			Function: "init",
+			Class:    callee.Name,
+			Call:     call,
+		}, true
+	}
+
+	return
+}
+
+// pushFrame records a call, popFrame must be called when it returns.
+func (i *Interpreter) pushFrame(frame Frame) {
+	i.frames = append(i.frames, frame)
+}
+
+func (i *Interpreter) popFrame() {
+	i.frames = i.frames[:len(i.frames)-1]
+}
+
+// stackTrace returns a copy of the current call stack.
+func (i *Interpreter) stackTrace() StackTrace {
+	trace := make(StackTrace, len(i.frames))
+	copy(trace, i.frames)
+	return trace
+}
+
+// traceEnds is how many frames WriteTrace writes at each end of a long stack trace.
+const traceEnds = 10
+
+// WriteTrace writes the stack trace of r, the most recent call first, like:
+//
+//	stack trace (most recent call first):
+//	  at Cell.flip (main.lox:12:15)
+//	  at step (main.lox:30:5)
+//	  at <script> (main.lox:42:1)
+//
+// Nothing is written if r happens out of any function.
+// Frames in imported modules are written with paths of the modules instead of filename.
+func (r *RuntimeError) WriteTrace(w io.Writer, filename string) (err error) {
+	if len(r.Trace) == 0 {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("stack trace (most recent call first):\n")
+
+	for index := len(r.Trace) - 1; index >= 0; index-- {
+		if omitted := len(r.Trace) - 2*traceEnds; omitted > 0 && index == len(r.Trace)-1-traceEnds {
+			// deep recursion
+			_, _ = fmt.Fprintf(&b, "  ... %d more\n", omitted)
+			index -= omitted - 1
+			continue
+		}
+
+		// where the execution is in this frame
+		var at token.Span
+		if index == len(r.Trace)-1 {
+			if r.Token != nil {
+				at = r.Token.Span()
+			}
+		} else {
+			at = r.Trace[index+1].Call
+		}
+
+		_, _ = fmt.Fprintf(&b, "  at %s (%s)\n", r.Trace[index], location(filename, at))
+	}
+	_, _ = fmt.Fprintf(&b, "  at <script> (%s)\n", location(filename, r.Trace[0].Call))
+
+	_, err = io.WriteString(w, b.String())
+	return
+}
+
+func location(filename string, span token.Span) string {
+	if span.File != "" {
+		// in an imported module
+		filename = span.File
+	}
+	if span.Line <= 0 {
+		return filename
+	}
+
+	return filename + ":" + span.String()
+}
@@ -3,10 +3,14 @@ package lox
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 
+	"github.com/nanmu42/bluelox/ast"
+	"github.com/nanmu42/bluelox/diag"
+
 	"github.com/nanmu42/bluelox/resolver"
 
 	"github.com/nanmu42/bluelox/interpreter"
@@ -18,14 +22,26 @@ import (
 
 type Lox struct {
 	interpreter *interpreter.Interpreter
+	// reads sources of imported modules, see SetModuleReader
+	moduleReader interpreter.FileReader
+	// sources of loaded modules by path, for rendering errors
+	sources map[string][]byte
 }
 
+// NewLox returns a Lox writing to stdout.
+//
+// Scripts can not read input, files or import modules by default,
+// see ChangeStdinTo, SetFileReader and SetModuleReader.
 func NewLox(stdout io.Writer) *Lox {
 	return &Lox{
 		interpreter: interpreter.NewInterpreter(stdout),
+		sources:     make(map[string][]byte),
 	}
 }
 
+// RunFile runs the script at path,
+// modules imported by it are resolved relative to its directory,
+// and read by the reader set by SetModuleReader.
 func (l *Lox) RunFile(ctx context.Context, path string) (err error) {
 	script, err := os.ReadFile(path)
 	if err != nil {
@@ -33,6 +49,11 @@ func (l *Lox) RunFile(ctx context.Context, path string) (err error) {
 		return
 	}
 
+	err = l.SetScriptPath(path)
+	if err != nil {
+		return
+	}
+
 	err = l.Run(ctx, script)
 	if err != nil {
 		err = fmt.Errorf("running script: %w", err)
@@ -42,6 +63,13 @@ func (l *Lox) RunFile(ctx context.Context, path string) (err error) {
 	return
 }
 
+// SetScriptPath tells where the script passed to Run comes from,
+// modules imported by it are resolved relative to its directory
+// instead of the working directory.
+func (l *Lox) SetScriptPath(path string) (err error) {
+	return l.interpreter.SetScriptPath(path)
+}
+
 func (l *Lox) RunPrompt(ctx context.Context) (err error) {
 	lineReader := bufio.NewScanner(os.Stdin)
 
@@ -54,7 +82,7 @@ func (l *Lox) RunPrompt(ctx context.Context) (err error) {
 
 		err = l.Run(ctx, line)
 		if err != nil {
-			fmt.Println(err)
+			_ = l.RenderError(os.Stdout, "<stdin>", line, err)
 		}
 
 		fmt.Printf("> ")
@@ -70,11 +98,31 @@ func (l *Lox) RunPrompt(ctx context.Context) (err error) {
 }
 
 // Run provided script.
-// context is used to early stop interpretation on statement level.
+// context is used to stop interpretation early,
+// which is checked on loops, calls, blocks and sleep().
 //
 // The provided script is read only, should not be modified.
+//
+// Use diag.Collect on err to get structured diagnostics.
 func (l *Lox) Run(ctx context.Context, script []byte) (err error) {
+	stmts, err := l.compile(script, "")
+	if err != nil {
+		return
+	}
+
+	err = l.interpreter.Interpret(ctx, stmts)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// compile scans, parses and resolves script,
+// file is the path of the module, empty for the main script.
+func (l *Lox) compile(script []byte, file string) (stmts []ast.Statement, err error) {
 	s := scanner.NewScanner(script)
+	s.SetFile(file)
 	tokens, err := s.ScanTokens()
 	if err != nil {
 		err = fmt.Errorf("scaning tokens: %w", err)
@@ -82,7 +130,7 @@ func (l *Lox) Run(ctx context.Context, script []byte) (err error) {
 	}
 
 	p := parser.NewParser(tokens)
-	stmts, err := p.Parse()
+	stmts, err = p.Parse()
 	if err != nil {
 		return
 	}
@@ -94,14 +142,229 @@ func (l *Lox) Run(ctx context.Context, script []byte) (err error) {
 		return
 	}
 
-	err = l.interpreter.Interpret(ctx, stmts)
+	return
+}
+
+// SetModuleReader sets how sources of imported modules are read,
+// import statements fail if reader is nil, which is the default.
+//
+// reader is called with absolute paths, os.ReadFile is one which reads any file.
+func (l *Lox) SetModuleReader(reader interpreter.FileReader) {
+	l.moduleReader = reader
+	if reader == nil {
+		l.interpreter.SetModuleLoader(nil)
+		return
+	}
+
+	l.interpreter.SetModuleLoader(l.loadModule)
+}
+
+// loadModule is the interpreter.ModuleLoader of l.
+func (l *Lox) loadModule(path string) (stmts []ast.Statement, err error) {
+	script, err := l.moduleReader(path)
 	if err != nil {
+		err = fmt.Errorf("reading module file: %w", err)
+		return
+	}
+
+	l.sources[path] = script
+	return l.compile(script, path)
+}
+
+// RenderError writes err returned by Run in a human friendly way,
+// with source excerpts and the stack trace if there is any.
+//
+// filename is how the script is called, and script is what is passed to Run.
+// Errors in imported modules are written with their paths,
+// but without excerpts, see Lox.RenderError.
+func RenderError(w io.Writer, filename string, script []byte, err error) (renderErr error) {
+	return renderError(w, filename, script, err, nil)
+}
+
+// RenderError is the package level RenderError,
+// which also writes excerpts of modules imported by scripts run by l.
+func (l *Lox) RenderError(w io.Writer, filename string, script []byte, err error) (renderErr error) {
+	return renderError(w, filename, script, err, func(path string) (source []byte, ok bool) {
+		source, ok = l.sources[path]
+		return
+	})
+}
+
+func renderError(w io.Writer, filename string, script []byte, err error, sources diag.Sources) (renderErr error) {
+	renderErr = diag.RenderSources(w, filename, script, diag.Collect(err, diag.PhaseRuntime), sources)
+	if renderErr != nil {
 		return
 	}
 
+	var runtimeErr *interpreter.RuntimeError
+	if errors.As(err, &runtimeErr) {
+		renderErr = runtimeErr.WriteTrace(w, filename)
+	}
+
 	return
 }
 
 func (l *Lox) ChangeStdoutTo(writer io.Writer) {
 	l.interpreter.ChangeStdoutTo(writer)
 }
+
+// SetLimits bounds steps, call depth and memory of each Run and Call,
+// exceeding them results in a RuntimeError caused by
+// interpreter.StepLimitError, interpreter.CallDepthError or interpreter.MemoryLimitError.
+func (l *Lox) SetLimits(limits interpreter.Limits) {
+	l.interpreter.SetLimits(limits)
+}
+
+// ChangeStdinTo changes where IO.readLine reads from,
+// IO.readLine fails if reader is nil, which is the default.
+func (l *Lox) ChangeStdinTo(reader io.Reader) {
+	l.interpreter.ChangeStdinTo(reader)
+}
+
+// SetFileReader sets how IO.readFile reads files,
+// IO.readFile fails if reader is nil, which is the default.
+//
+// os.ReadFile is one which reads any file.
+func (l *Lox) SetFileReader(reader interpreter.FileReader) {
+	l.interpreter.SetFileReader(reader)
+}
+
+// Value is a Lox value seen from Go, which is one of
+// nil, bool, float64, string, *interpreter.List, *interpreter.Map,
+// *interpreter.Class, *interpreter.Instance, *interpreter.GoObject,
+// *interpreter.Namespace and interpreter.Callable.
+type Value = interface{}
+
+// NativeFunc is a Go function callable from scripts,
+// the returned error becomes a runtime error at the call site.
+type NativeFunc func(args []Value) (Value, error)
+
+// DefineNative makes fn callable as a global function name in scripts and modules,
+// scripts must call it with exactly arity arguments.
+func (l *Lox) DefineNative(name string, arity int, fn NativeFunc) {
+	l.interpreter.DefineNative(name, interpreter.NewNative(arity, fn))
+}
+
+// SetGlobal defines or assigns the global variable name of scripts run by l.
+func (l *Lox) SetGlobal(name string, value Value) (err error) {
+	return l.interpreter.SetGlobal(name, value)
+}
+
+// GetGlobal returns the global variable name of scripts run by l,
+// ok is false if it's not defined.
+func (l *Lox) GetGlobal(name string) (value Value, ok bool) {
+	return l.interpreter.GetGlobal(name)
+}
+
+// Bind converts v from Go by reflection and defines it as the global name,
+// functions are defined like DefineNative.
+//
+// Numbers, strings, booleans, slices, maps, structs and functions are converted,
+// arguments and results of functions are converted on calls,
+// structs show their exported fields and methods as properties.
+// Pointers to structs are shared with scripts, while slices and maps are copied,
+// so scripts have to assign a field to change a slice or map in it.
+// See interpreter.ToValue for details.
+func (l *Lox) Bind(name string, v interface{}) (err error) {
+	value, err := interpreter.ToValue(v)
+	if err != nil {
+		err = fmt.Errorf("binding %q: %w", name, err)
+		return
+	}
+
+	if native, ok := value.(interpreter.Callable); ok {
+		l.interpreter.DefineNative(name, native)
+		return
+	}
+
+	return l.interpreter.SetGlobal(name, value)
+}
+
+// Function is a handle of a Lox function, bound method or class,
+// which can be called from Go many times.
+type Function struct {
+	lox      *Lox
+	callable interpreter.Callable
+}
+
+// Arity returns how many arguments the function takes.
+func (f *Function) Arity() int {
+	return f.callable.Arity()
+}
+
+// Call calls the function with args converted like Bind,
+// use ConvertValue to convert the result into Go.
+//
+// ctx is used in the same way as Run.
+func (f *Function) Call(ctx context.Context, args ...interface{}) (result Value, err error) {
+	values := make([]interface{}, len(args))
+	for index, arg := range args {
+		values[index], err = interpreter.ToValue(arg)
+		if err != nil {
+			err = fmt.Errorf("argument at index %d: %w", index, err)
+			return
+		}
+	}
+
+	return f.lox.interpreter.Call(ctx, f.callable, values)
+}
+
+// Function returns the handle of global function or class name,
+// which is usually defined by scripts run before.
+func (l *Lox) Function(name string) (fn *Function, err error) {
+	value, ok := l.GetGlobal(name)
+	if !ok {
+		err = fmt.Errorf("undefined global %q", name)
+		return
+	}
+
+	callable, ok := value.(interpreter.Callable)
+	if !ok {
+		err = fmt.Errorf("global %q is not callable", name)
+		return
+	}
+
+	fn = &Function{
+		lox:      l,
+		callable: callable,
+	}
+	return
+}
+
+// Method returns the handle of method name bound to object,
+// which is an instance or a class got from GetGlobal or Call.
+func (l *Lox) Method(object Value, name string) (fn *Function, err error) {
+	property, err := interpreter.Property(object, name)
+	if err != nil {
+		err = fmt.Errorf("getting method %q: %w", name, err)
+		return
+	}
+
+	callable, ok := property.(interpreter.Callable)
+	if !ok {
+		err = fmt.Errorf("property %q is not callable", name)
+		return
+	}
+
+	fn = &Function{
+		lox:      l,
+		callable: callable,
+	}
+	return
+}
+
+// Call calls global function name with args, see Function.
+func (l *Lox) Call(ctx context.Context, name string, args ...interface{}) (result Value, err error) {
+	fn, err := l.Function(name)
+	if err != nil {
+		return
+	}
+
+	return fn.Call(ctx, args...)
+}
+
+// ConvertValue converts value from Lox into what target points to,
+// which is the opposite of Bind.
+func ConvertValue(value Value, target interface{}) (err error) {
+	return interpreter.FromValue(value, target)
+}
@@ -2,10 +2,20 @@ package lox
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/nanmu42/bluelox/diag"
+	"github.com/nanmu42/bluelox/interpreter"
 )
 
 func ExampleLox_logics() {
@@ -497,3 +507,1758 @@ fun bad() {
 	err := l.Run(context.TODO(), []byte(code))
 	require.Error(t, err)
 }
+
+func Test_Lox_diagnostics(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      string
+		wantPhase diag.Phase
+		wantCode  diag.Code
+		wantSpan  string
+	}{
+		{
+			name:      "scanning",
+			code:      "var a = 1;\nvar b = @;",
+			wantPhase: diag.PhaseScan,
+			wantCode:  diag.CodeUnexpectedCharacter,
+			wantSpan:  "2:9",
+		},
+		{
+			name:      "parsing",
+			code:      "print (1 + 2;",
+			wantPhase: diag.PhaseParse,
+			wantCode:  diag.CodeUnexpectedToken,
+			wantSpan:  "1:13",
+		},
+		{
+			name:      "resolving",
+			code:      "fun bad() {\n  var a = 1;\n  var a = 2;\n}",
+			wantPhase: diag.PhaseResolve,
+			wantCode:  diag.CodeDuplicateDeclaration,
+			wantSpan:  "3:7",
+		},
+		{
+			name:      "runtime",
+			code:      "var a = 1;\nprint a / 0;",
+			wantPhase: diag.PhaseRuntime,
+			wantCode:  diag.CodeDivisionByZero,
+			wantSpan:  "2:9",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLox(io.Discard)
+			err := l.Run(context.TODO(), []byte(tt.code))
+			require.Error(t, err)
+
+			diags := diag.Collect(err, diag.PhaseRuntime)
+			require.Len(t, diags, 1)
+			require.Equal(t, tt.wantPhase, diags[0].Phase)
+			require.Equal(t, tt.wantCode, diags[0].Code)
+			require.Equal(t, tt.wantSpan, diags[0].Span.String())
+		})
+	}
+}
+
+func Test_Lox_resolving_errors_collected(t *testing.T) {
+	const code = `return 1;
+print this;
+fun bad(a, a) {
+  var b = b;
+}
+class Oops < Oops {
+  init() {
+    return 2;
+  }
+}
+`
+
+	l := NewLox(io.Discard)
+	err := l.Run(context.TODO(), []byte(code))
+	require.Error(t, err)
+
+	diags := diag.Collect(err, diag.PhaseRuntime)
+	var got []string
+	for _, d := range diags {
+		require.Equal(t, diag.PhaseResolve, d.Phase)
+		got = append(got, d.Span.String()+" "+string(d.Code))
+	}
+	require.Equal(t, []string{
+		"1:1 top-level-return",
+		"2:7 this-outside-class",
+		"3:12 duplicate-declaration",
+		"4:11 read-in-own-initializer",
+		"6:14 self-inheritance",
+		"8:12 return-from-initializer",
+	}, got)
+	require.Contains(t, err.Error(), "got 6 resolving error(s)")
+}
+
+func Test_Lox_stack_trace(t *testing.T) {
+	const code = `class Cell {
+  init(v) {
+    this.v = v;
+  }
+  flip(x) {
+    return this.v / x;
+  }
+}
+fun step(c) {
+  return c.flip("x");
+}
+print step(Cell(1));
+`
+
+	l := NewLox(io.Discard)
+	err := l.Run(context.TODO(), []byte(code))
+	require.Error(t, err)
+
+	var runtimeErr *interpreter.RuntimeError
+	require.ErrorAs(t, err, &runtimeErr)
+	require.Len(t, runtimeErr.Trace, 2)
+	require.Equal(t, "step", runtimeErr.Trace[0].String())
+	require.Equal(t, "12:7", runtimeErr.Trace[0].Call.String())
+	require.Equal(t, "Cell.flip", runtimeErr.Trace[1].String())
+	require.Equal(t, "10:10", runtimeErr.Trace[1].Call.String())
+
+	var b strings.Builder
+	require.NoError(t, RenderError(&b, "main.lox", []byte(code), err))
+	require.Equal(t, `main.lox:6:19: error: operand(s) must be number(s), got "x"(type string)
+  |
+6 |     return this.v / x;
+  |                   ^
+stack trace (most recent call first):
+  at Cell.flip (main.lox:6:19)
+  at step (main.lox:10:10)
+  at <script> (main.lox:12:7)
+`, b.String())
+}
+
+func Test_Lox_no_stack_trace_at_top_level(t *testing.T) {
+	l := NewLox(io.Discard)
+	err := l.Run(context.TODO(), []byte(`print 1 / nil;`))
+	require.Error(t, err)
+
+	var runtimeErr *interpreter.RuntimeError
+	require.ErrorAs(t, err, &runtimeErr)
+	require.Empty(t, runtimeErr.Trace)
+}
+
+// brokenWriter fails every write.
+type brokenWriter struct{}
+
+func (brokenWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func Test_Lox_stack_trace_of_go_errors(t *testing.T) {
+	const code = `fun f() {
+  print 1;
+}
+fun g() {
+  f();
+}
+g();
+`
+
+	l := NewLox(brokenWriter{})
+	err := l.Run(context.TODO(), []byte(code))
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "calling function")
+
+	var runtimeErr *interpreter.RuntimeError
+	require.ErrorAs(t, err, &runtimeErr)
+	require.Equal(t, "printing to io.Writer: broken pipe", runtimeErr.Reason)
+	require.Equal(t, "5:5", runtimeErr.Token.Span().String())
+	require.Len(t, runtimeErr.Trace, 2)
+	require.Equal(t, "g", runtimeErr.Trace[0].Function)
+	require.Equal(t, "f", runtimeErr.Trace[1].Function)
+}
+
+func BenchmarkLox_fib(b *testing.B) {
+	const code = `
+fun fib(n) {
+  if (n <= 1) return n;
+  return fib(n - 2) + fib(n - 1);
+}
+
+fib(20);
+`
+
+	l := NewLox(io.Discard)
+	for i := 0; i < b.N; i++ {
+		err := l.Run(context.TODO(), []byte(code))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func ExampleLox_return() {
+	const code = `
+fun find(n) {
+  var i = 0;
+  while (true) {
+    {
+      if (i * i >= n) return i;
+    }
+    i = i + 1;
+  }
+}
+
+class Box {
+  init(v) {
+    this.v = v;
+    if (v) return;
+    this.v = "empty";
+  }
+}
+
+fun nothing() {
+  return;
+}
+
+print find(50);
+print Box(1).v;
+print Box(false).v;
+print nothing();
+`
+
+	l := NewLox(os.Stdout)
+	err := l.Run(context.TODO(), []byte(code))
+	if err != nil {
+		panic(err)
+	}
+	// Output:
+	// 8
+	// 1
+	// empty
+	// nil
+}
+
+func ExampleLox_break_continue() {
+	const code = `
+for (var i = 0; i < 10; i = i + 1) {
+  if (i == 1) continue;
+  if (i == 4) break;
+  print i;
+}
+
+var n = 0;
+while (true) {
+  n = n + 1;
+  if (n < 3) {
+    continue;
+  }
+  for (;;) {
+    break;
+  }
+  fun inner() {
+    for (var j = 0; j < 3; j = j + 1) {
+      if (j == 1) return j;
+    }
+  }
+  print "inner " + "returns";
+  print inner();
+  break;
+}
+print n;
+`
+
+	l := NewLox(os.Stdout)
+	err := l.Run(context.TODO(), []byte(code))
+	if err != nil {
+		panic(err)
+	}
+	// Output:
+	// 0
+	// 2
+	// 3
+	// inner returns
+	// 1
+	// 3
+}
+
+func Test_Lox_no_jumping_outside_loop(t *testing.T) {
+	const code = `break;
+while (true) {
+  fun f() {
+    continue;
+  }
+  break;
+}
+`
+
+	l := NewLox(io.Discard)
+	err := l.Run(context.TODO(), []byte(code))
+	require.Error(t, err)
+
+	diags := diag.Collect(err, diag.PhaseRuntime)
+	require.Len(t, diags, 2)
+	require.Equal(t, diag.CodeOutsideLoop, diags[0].Code)
+	require.Equal(t, "1:1", diags[0].Span.String())
+	require.Equal(t, diag.CodeOutsideLoop, diags[1].Code)
+	require.Equal(t, "4:5", diags[1].Span.String())
+}
+
+func ExampleLox_anonymous_function() {
+	const code = `
+fun apply(f, x) {
+  return f(x);
+}
+
+fun makeCounter() {
+  var count = 0;
+  return fun () {
+    count = count + 1;
+    return count;
+  };
+}
+
+var double = fun (a) {
+  return a * 2;
+};
+
+print apply(fun (x) {
+  return x + 1;
+}, 2);
+print double(4);
+print double;
+
+var counter = makeCounter();
+counter();
+print counter();
+
+fun () {
+  print "called at once";
+}();
+`
+
+	l := NewLox(os.Stdout)
+	err := l.Run(context.TODO(), []byte(code))
+	if err != nil {
+		panic(err)
+	}
+	// Output:
+	// 3
+	// 8
+	// <anonymous fn>
+	// 2
+	// called at once
+}
+
+func ExampleLox_list() {
+	const code = `
+var xs = [1, "two", [3, nil], true];
+print xs;
+print xs[1];
+xs[0] = xs[0] + 10;
+xs.push(5);
+print xs.length();
+print xs.pop();
+print xs.slice(1, 3);
+print xs[2][0];
+
+var squares = [];
+for (var i = 0; i < 4; i = i + 1) {
+  squares.push(i * i);
+}
+print squares;
+
+xs.push(xs);
+print xs;
+print [] == [];
+print xs == xs;
+`
+
+	l := NewLox(os.Stdout)
+	err := l.Run(context.TODO(), []byte(code))
+	if err != nil {
+		panic(err)
+	}
+	// Output:
+	// [1, "two", [3, nil], true]
+	// two
+	// 5
+	// 5
+	// ["two", [3, nil]]
+	// 3
+	// [0, 1, 4, 9]
+	// [11, "two", [3, nil], true, [...]]
+	// false
+	// true
+}
+
+func Test_Lox_list_errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		wantCode diag.Code
+		wantSpan string
+	}{
+		{
+			name:     "out of range",
+			code:     "var xs = [1];\nprint xs[1];",
+			wantCode: diag.CodeIndexOutOfRange,
+			wantSpan: "2:9",
+		},
+		{
+			name:     "not integer",
+			code:     "var xs = [1];\nxs[0.5] = 1;",
+			wantCode: diag.CodeInvalidIndex,
+			wantSpan: "2:3",
+		},
+		{
+			name:     "not indexable",
+			code:     "var s = \"abc\";\nprint s[0];",
+			wantCode: diag.CodeOperandType,
+			wantSpan: "2:8",
+		},
+		{
+			name:     "pop from empty",
+			code:     "[].pop();",
+			wantCode: diag.CodeRuntime,
+			wantSpan: "1:8",
+		},
+		{
+			name:     "unknown method",
+			code:     "[].shift();",
+			wantCode: diag.CodeUndefinedProperty,
+			wantSpan: "1:4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLox(io.Discard)
+			err := l.Run(context.TODO(), []byte(tt.code))
+			require.Error(t, err)
+
+			diags := diag.Collect(err, diag.PhaseRuntime)
+			require.Len(t, diags, 1)
+			require.Equal(t, tt.wantCode, diags[0].Code)
+			require.Equal(t, tt.wantSpan, diags[0].Span.String())
+		})
+	}
+}
+
+func ExampleLox_map() {
+	const code = `
+var m = {"a": 1, 2: "two", true: nil, nil: [1]};
+print m;
+print m["a"];
+m["b"] = {};
+m[2] = "TWO";
+print m.length();
+print m.has("b");
+print m.has("c");
+print m.delete("a");
+print m.delete("a");
+
+var keys = m.keys();
+for (var i = 0; i < keys.length(); i = i + 1) {
+  print keys[i];
+}
+print m.values();
+
+var nan = 0 / 0;
+m[nan] = 1;
+m[nan] = 2;
+m[-0] = "zero";
+print m[0];
+print m;
+`
+
+	l := NewLox(os.Stdout)
+	err := l.Run(context.TODO(), []byte(code))
+	if err != nil {
+		panic(err)
+	}
+	// Output:
+	// {"a": 1, 2: "two", true: nil, nil: [1]}
+	// 1
+	// 5
+	// true
+	// false
+	// true
+	// false
+	// 2
+	// true
+	// nil
+	// b
+	// ["TWO", nil, [1], {}]
+	// zero
+	// {2: "TWO", true: nil, nil: [1], "b": {}, NaN: 2, -0: "zero"}
+}
+
+func Test_Lox_map_errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		wantCode diag.Code
+		wantSpan string
+	}{
+		{
+			name:     "key not found",
+			code:     "var m = {};\nprint m[\"a\"];",
+			wantCode: diag.CodeKeyNotFound,
+			wantSpan: "2:8",
+		},
+		{
+			name:     "invalid key in literal",
+			code:     "var m = {[]: 1};",
+			wantCode: diag.CodeInvalidIndex,
+			wantSpan: "1:9",
+		},
+		{
+			name:     "invalid key",
+			code:     "var m = {};\nm[m] = 1;",
+			wantCode: diag.CodeInvalidIndex,
+			wantSpan: "2:2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLox(io.Discard)
+			err := l.Run(context.TODO(), []byte(tt.code))
+			require.Error(t, err)
+
+			diags := diag.Collect(err, diag.PhaseRuntime)
+			require.Len(t, diags, 1)
+			require.Equal(t, tt.wantCode, diags[0].Code)
+			require.Equal(t, tt.wantSpan, diags[0].Span.String())
+		})
+	}
+}
+
+func ExampleLox_getter_and_static_method() {
+	const code = `
+class Math {
+  class square(n) {
+    return n * n;
+  }
+
+  class pi {
+    return 3.14;
+  }
+}
+
+class Circle {
+  init(radius) {
+    this.radius = radius;
+  }
+
+  area {
+    return Math.pi * Math.square(this.radius);
+  }
+}
+
+class Ring < Circle {
+  class unit() {
+    return this(1);
+  }
+
+  area {
+    return super.area / 2;
+  }
+}
+
+print Math.square(3);
+print Circle(2).area;
+print Ring.unit().area;
+print Ring.unit;
+`
+
+	l := NewLox(os.Stdout)
+	err := l.Run(context.TODO(), []byte(code))
+	if err != nil {
+		panic(err)
+	}
+	// Output:
+	// 9
+	// 12.56
+	// 1.57
+	// <fn unit>
+}
+
+func Test_Lox_getter_and_static_method_errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		wantCode diag.Code
+		wantSpan string
+	}{
+		{
+			name:     "getter initializer",
+			code:     "class A {\n  init {}\n}",
+			wantCode: diag.CodeGetterInitializer,
+			wantSpan: "2:3",
+		},
+		{
+			name:     "undefined static method",
+			code:     "class A {\n  m() {}\n}\nA.m();",
+			wantCode: diag.CodeUndefinedProperty,
+			wantSpan: "4:3",
+		},
+		{
+			name:     "error in getter",
+			code:     "class A {\n  g { return nil + 1; }\n}\nprint A().g;",
+			wantCode: diag.CodeOperandType,
+			wantSpan: "2:18",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLox(io.Discard)
+			err := l.Run(context.TODO(), []byte(tt.code))
+			require.Error(t, err)
+
+			diags := diag.Collect(err, diag.PhaseRuntime)
+			require.Len(t, diags, 1)
+			require.Equal(t, tt.wantCode, diags[0].Code)
+			require.Equal(t, tt.wantSpan, diags[0].Span.String())
+		})
+	}
+}
+
+func ExampleLox_string_interpolation() {
+	const code = `
+var name = "Lox";
+var scores = {"math": 90};
+class Point {
+  init(x, y) {
+    this.x = x;
+    this.y = y;
+  }
+}
+
+print "hello, ${name}!";
+print "1 + 2 = ${1 + 2}";
+print "scores: ${scores}, math: ${scores["math"]}";
+print "${Point(1, 2)} at (${Point(1, 2).x}, ${Point(1, 2).y})";
+print "nested: ${"${name}-${nil}"}";
+print "not embedded: \${name}";
+`
+
+	l := NewLox(os.Stdout)
+	err := l.Run(context.TODO(), []byte(code))
+	if err != nil {
+		panic(err)
+	}
+	// Output:
+	// hello, Lox!
+	// 1 + 2 = 3
+	// scores: {"math": 90}, math: 90
+	// Point instance at (1, 2)
+	// nested: Lox-nil
+	// not embedded: ${name}
+}
+
+func ExampleLox_operators() {
+	const code = `
+print 7 % 3;
+print -7 % 3;
+print 2 ** 3 ** 2;
+print -2 ** 2;
+
+var n = 5;
+print n % 2 == 0 ? "even" : "odd";
+print n > 10 ? "big" : n > 3 ? "medium" : "small";
+
+n += 1;
+n *= 2;
+n -= 2;
+n /= 5;
+print n;
+
+class Counter {
+  init() {
+    this.count = 0;
+  }
+}
+var counter = Counter();
+counter.count += 3;
+print counter.count;
+
+var xs = [1, 2];
+xs[0] += 10;
+var greeting = {"text": "hello"};
+greeting["text"] += ", world";
+print xs;
+print greeting;
+`
+
+	l := NewLox(os.Stdout)
+	err := l.Run(context.TODO(), []byte(code))
+	if err != nil {
+		panic(err)
+	}
+	// Output:
+	// 1
+	// -1
+	// 512
+	// -4
+	// odd
+	// medium
+	// 2
+	// 3
+	// [11, 2]
+	// {"text": "hello, world"}
+}
+
+func ExampleLox_exceptions() {
+	const code = `
+fun divide(a, b) {
+  if (b == 0) throw "division by zero";
+  return a / b;
+}
+
+try {
+  print divide(1, 0);
+} catch (e) {
+  print "caught: ${e}";
+}
+
+try {
+  print nil + 1;
+} catch (e) {
+  print "${e} at line ${e.line}: ${e.message}";
+}
+
+fun withCleanup() {
+  try {
+    return "returned";
+  } finally {
+    print "cleaned up";
+  }
+}
+print withCleanup();
+
+try {
+  try {
+    throw {"code": 42};
+  } finally {
+    print "inner finally";
+  }
+} catch (e) {
+  print e["code"];
+}
+`
+
+	l := NewLox(os.Stdout)
+	err := l.Run(context.TODO(), []byte(code))
+	if err != nil {
+		panic(err)
+	}
+	// Output:
+	// caught: division by zero
+	// Error instance at line 14: operands must be both numbers or strings, got <nil>(<nil>) and 1(float64)
+	// cleaned up
+	// returned
+	// inner finally
+	// 42
+}
+
+func Test_Lox_uncaught_exception(t *testing.T) {
+	const code = `fun fail() {
+  throw "oops";
+}
+try {
+  fail();
+} finally {
+  print "finally";
+}
+`
+
+	var out strings.Builder
+	l := NewLox(&out)
+	err := l.Run(context.TODO(), []byte(code))
+	require.Error(t, err)
+	require.Equal(t, "finally\n", out.String())
+
+	var runtimeErr *interpreter.RuntimeError
+	require.ErrorAs(t, err, &runtimeErr)
+	require.Equal(t, diag.CodeUncaughtException, runtimeErr.Code)
+	require.Equal(t, "oops", runtimeErr.Value)
+	require.Equal(t, "uncaught exception: oops", runtimeErr.Reason)
+	require.Equal(t, "2:3", runtimeErr.Token.Span().String())
+	require.Len(t, runtimeErr.Trace, 1)
+	require.Equal(t, "fail", runtimeErr.Trace[0].Function)
+}
+
+// writeModules writes files into a temporary directory, and returns the directory.
+func writeModules(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	return dir
+}
+
+func Test_Lox_import(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.lox": `import { square, Point } from "lib/math.lox";
+import "lib/counter.lox";
+print square(4);
+print Point(1, 2).sum();
+print next();
+print next();
+`,
+		"lib/math.lox": `print "evaluating math";
+var offset = 0;
+export fun square(n) {
+  return n * n + offset;
+}
+export class Point {
+  init(x, y) {
+    this.x = x;
+    this.y = y;
+  }
+  sum() {
+    return this.x + this.y;
+  }
+}
+`,
+		"lib/counter.lox": `import { square } from "math.lox";
+var count = 0;
+export fun next() {
+  count += 1;
+  return square(count);
+}
+`,
+	})
+
+	var out strings.Builder
+	l := NewLox(&out)
+	l.SetModuleReader(os.ReadFile)
+	err := l.RunFile(context.TODO(), filepath.Join(dir, "main.lox"))
+	require.NoError(t, err)
+	// math is evaluated once, though imported twice
+	require.Equal(t, "evaluating math\n16\n3\n1\n4\n", out.String())
+}
+
+func Test_Lox_import_copies_values(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"counter.lox": `export var count = 0;
+export var history = [];
+export fun next() {
+  count += 1;
+  history.push(count);
+  return count;
+}
+`,
+		"main.lox": `import { count, history, next } from "counter.lox";
+print next();
+print count;
+print history;
+`,
+	})
+
+	var out strings.Builder
+	l := NewLox(&out)
+	l.SetModuleReader(os.ReadFile)
+	require.NoError(t, l.RunFile(context.TODO(), filepath.Join(dir, "main.lox")))
+	// count is copied at import, while the list is shared
+	require.Equal(t, "1\n0\n[1]\n", out.String())
+}
+
+func Test_Lox_import_errors(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"a.lox":          "import \"b.lox\";\nexport var a = 1;\n",
+		"b.lox":          "import \"a.lox\";\n",
+		"private.lox":    "var hidden = 1;\nexport var shown = 2;\n",
+		"broken.lox":     "var x = nil + 1;\n",
+		"not_parsed.lox": "var = 1;\n",
+	})
+
+	tests := []struct {
+		name       string
+		code       string
+		wantCode   diag.Code
+		wantReason string
+	}{
+		{
+			name:       "cycle",
+			code:       `import "a.lox";`,
+			wantCode:   diag.CodeImport,
+			wantReason: `evaluating module "a.lox": evaluating module "b.lox": import cycle: a.lox -> b.lox -> a.lox`,
+		},
+		{
+			name:       "not exported",
+			code:       `import { hidden } from "private.lox";`,
+			wantCode:   diag.CodeNotExported,
+			wantReason: `"hidden" is not exported by module "private.lox"`,
+		},
+		{
+			name:       "runtime error in module",
+			code:       `import "broken.lox";`,
+			wantCode:   diag.CodeImport,
+			wantReason: `evaluating module "broken.lox": broken.lox:1:13: operands must be both numbers or strings, got <nil>(<nil>) and 1(float64)`,
+		},
+		{
+			name:     "syntax error in module",
+			code:     `import "not_parsed.lox";`,
+			wantCode: diag.CodeImport,
+		},
+		{
+			name:     "no such module",
+			code:     `import "nowhere.lox";`,
+			wantCode: diag.CodeImport,
+		},
+		{
+			name:     "private names are not imported",
+			code:     "import \"private.lox\";\nprint shown;\nprint hidden;",
+			wantCode: diag.CodeUndefinedVariable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLox(io.Discard)
+			l.SetModuleReader(os.ReadFile)
+			require.NoError(t, l.SetScriptPath(filepath.Join(dir, "main.lox")))
+			err := l.Run(context.TODO(), []byte(tt.code))
+			require.Error(t, err)
+
+			var runtimeErr *interpreter.RuntimeError
+			require.ErrorAs(t, err, &runtimeErr)
+			require.Equal(t, tt.wantCode, runtimeErr.Code)
+			if tt.wantReason != "" {
+				require.Equal(t, tt.wantReason, runtimeErr.Reason)
+			}
+		})
+	}
+}
+
+func Test_Lox_import_not_top_level(t *testing.T) {
+	const code = `{
+  import "a.lox";
+}
+fun f() {
+  export var x = 1;
+}
+`
+
+	l := NewLox(io.Discard)
+	err := l.Run(context.TODO(), []byte(code))
+	require.Error(t, err)
+
+	diags := diag.Collect(err, diag.PhaseRuntime)
+	require.Len(t, diags, 2)
+	require.Equal(t, diag.CodeNotTopLevel, diags[0].Code)
+	require.Equal(t, "2:3", diags[0].Span.String())
+	require.Equal(t, diag.CodeNotTopLevel, diags[1].Code)
+	require.Equal(t, "5:3", diags[1].Span.String())
+}
+
+func Test_Lox_error_in_imported_function(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"lib.lox": "export fun half(x) {\n  return x / 2;\n}\n",
+	})
+	lib := filepath.Join(dir, "lib.lox")
+	code := []byte("import { half } from \"lib.lox\";\nprint half(\"x\");\n")
+
+	l := NewLox(io.Discard)
+	l.SetModuleReader(os.ReadFile)
+	require.NoError(t, l.SetScriptPath(filepath.Join(dir, "main.lox")))
+	err := l.Run(context.TODO(), code)
+	require.Error(t, err)
+
+	diags := diag.Collect(err, diag.PhaseRuntime)
+	require.Len(t, diags, 1)
+	require.Equal(t, lib, diags[0].Span.File)
+	require.Equal(t, "2:12", diags[0].Span.String())
+
+	var runtimeErr *interpreter.RuntimeError
+	require.ErrorAs(t, err, &runtimeErr)
+	require.Len(t, runtimeErr.Trace, 1)
+	require.Empty(t, runtimeErr.Trace[0].Call.File)
+
+	var b strings.Builder
+	require.NoError(t, l.RenderError(&b, "main.lox", code, err))
+	require.Equal(t, lib+`:2:12: error: operand(s) must be number(s), got "x"(type string)
+  |
+2 |   return x / 2;
+  |            ^
+stack trace (most recent call first):
+  at half (`+lib+`:2:12)
+  at <script> (main.lox:2:7)
+`, b.String())
+
+	// without sources of modules
+	b.Reset()
+	require.NoError(t, RenderError(&b, "main.lox", code, err))
+	require.True(t, strings.HasPrefix(b.String(), lib+`:2:12: error: operand(s) must be number(s), got "x"(type string)
+stack trace (most recent call first):
+`), b.String())
+}
+
+func ExampleLox_stdlib() {
+	const code = `
+print Math.sqrt(16) + Math.pow(2, 10);
+print Math.floor(-1.5) + Math.abs(-3);
+print Math.min(1, 2) + Math.max(1, 2);
+
+var s = "你好, Lox";
+print String.len(s);
+print String.substr(s, 4, 7);
+print String.upper(s) + String.lower("!LOX");
+print String.split("a,b,c", ",");
+print String.indexOf(s, "Lox");
+
+print str([1, "a"]) + str(nil);
+print num("3.5") + num(true);
+print parse("42") + 1;
+print parse("forty-two");
+
+print type(1) + " " + type("") + " " + type(nil) + " " + type(true);
+print type([]) + " " + type({}) + " " + type(clock) + " " + type(Math);
+class A {}
+print type(A) + " " + type(A()) + " " + type(Math.sqrt);
+print Math;
+
+var line = IO.readLine();
+while (line != nil) {
+  print "read: ${line}";
+  line = IO.readLine();
+}
+`
+
+	l := NewLox(os.Stdout)
+	l.ChangeStdinTo(strings.NewReader("first\r\nsecond"))
+	err := l.Run(context.TODO(), []byte(code))
+	if err != nil {
+		panic(err)
+	}
+	// Output:
+	// 1028
+	// 1
+	// 3
+	// 7
+	// Lox
+	// 你好, LOX!lox
+	// ["a", "b", "c"]
+	// 4
+	// [1, "a"]nil
+	// 4.5
+	// 43
+	// nil
+	// number string nil boolean
+	// list map function namespace
+	// class instance function
+	// <namespace Math>
+	// read: first
+	// read: second
+}
+
+func Test_Lox_stdlib_errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       string
+		wantCode   diag.Code
+		wantSpan   string
+		wantReason string
+	}{
+		{
+			name:       "undefined member",
+			code:       "Math.nope(1);",
+			wantCode:   diag.CodeUndefinedProperty,
+			wantSpan:   "1:6",
+			wantReason: `undefined property "nope" of namespace Math`,
+		},
+		{
+			name:       "wrong argument type",
+			code:       `Math.sqrt("4");`,
+			wantCode:   diag.CodeRuntime,
+			wantSpan:   "1:14",
+			wantReason: "sqrt() requires a number, not string",
+		},
+		{
+			name:       "substr out of range",
+			code:       `String.substr("abc", 1, 4);`,
+			wantCode:   diag.CodeRuntime,
+			wantSpan:   "1:26",
+			wantReason: "substr() bounds [1, 4) out of range with length 3",
+		},
+		{
+			name:       "num of bad string",
+			code:       `num("x");`,
+			wantCode:   diag.CodeRuntime,
+			wantSpan:   "1:8",
+			wantReason: `num() can not convert "x" into a number`,
+		},
+		{
+			name:       "arity",
+			code:       `String.split("a");`,
+			wantCode:   diag.CodeArityMismatch,
+			wantSpan:   "1:17",
+			wantReason: "function expected 2 arguments but got 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLox(io.Discard)
+			err := l.Run(context.TODO(), []byte(tt.code))
+			require.Error(t, err)
+
+			diags := diag.Collect(err, diag.PhaseRuntime)
+			require.Len(t, diags, 1)
+			require.Equal(t, tt.wantCode, diags[0].Code)
+			require.Equal(t, tt.wantSpan, diags[0].Span.String())
+			require.Equal(t, tt.wantReason, diags[0].Message)
+		})
+	}
+}
+
+func Test_Lox_io_opt_in(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       string
+		wantCode   diag.Code
+		wantReason string
+	}{
+		{
+			name:       "readLine",
+			code:       `IO.readLine();`,
+			wantCode:   diag.CodeRuntime,
+			wantReason: "readLine(): reading input is not allowed here",
+		},
+		{
+			name:       "readFile",
+			code:       `IO.readFile("lox_test.go");`,
+			wantCode:   diag.CodeRuntime,
+			wantReason: "readFile(): reading files is not allowed here",
+		},
+		{
+			name:       "import",
+			code:       `import "lox_test.go";`,
+			wantCode:   diag.CodeImport,
+			wantReason: "importing is not supported here",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLox(io.Discard)
+			err := l.Run(context.TODO(), []byte(tt.code))
+			require.Error(t, err)
+
+			diags := diag.Collect(err, diag.PhaseRuntime)
+			require.Len(t, diags, 1)
+			require.Equal(t, tt.wantCode, diags[0].Code)
+			require.Equal(t, tt.wantReason, diags[0].Message)
+		})
+	}
+
+	// files of the host application instead of the file system
+	files := map[string]string{
+		"/app/note.txt": "hello",
+		"/app/lib.lox":  `export var greeting = IO.readFile("/app/note.txt");`,
+	}
+	reader := func(path string) ([]byte, error) {
+		content, ok := files[path]
+		if !ok {
+			return nil, fmt.Errorf("no file %s", path)
+		}
+		return []byte(content), nil
+	}
+
+	var out strings.Builder
+	l := NewLox(&out)
+	l.SetFileReader(reader)
+	l.SetModuleReader(reader)
+	require.NoError(t, l.SetScriptPath("/app/main.lox"))
+	require.NoError(t, l.Run(context.TODO(), []byte(`import { greeting } from "lib.lox";
+print greeting;`)))
+	require.Equal(t, "hello\n", out.String())
+
+	err := l.Run(context.TODO(), []byte(`IO.readFile("/etc/passwd");`))
+	require.Error(t, err)
+	require.Equal(t, "readFile(): no file /etc/passwd", diag.Collect(err, diag.PhaseRuntime)[0].Message)
+}
+
+func ExampleLox_DefineNative() {
+	const code = `
+print greet(name);
+print "${name} has ${String.len(name)} characters";
+var answer = double(21);
+`
+
+	l := NewLox(os.Stdout)
+	l.DefineNative("greet", 1, func(args []Value) (Value, error) {
+		return "hello, " + args[0].(string), nil
+	})
+	l.DefineNative("double", 1, func(args []Value) (Value, error) {
+		n, ok := args[0].(float64)
+		if !ok {
+			return nil, errors.New("double() requires a number")
+		}
+		return n * 2, nil
+	})
+	err := l.SetGlobal("name", "Lox")
+	if err != nil {
+		panic(err)
+	}
+
+	err = l.Run(context.TODO(), []byte(code))
+	if err != nil {
+		panic(err)
+	}
+
+	answer, ok := l.GetGlobal("answer")
+	fmt.Println(answer, ok)
+	_, ok = l.GetGlobal("question")
+	fmt.Println(ok)
+	// Output:
+	// hello, Lox
+	// Lox has 3 characters
+	// 42 true
+	// false
+}
+
+func Test_Lox_DefineNative(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.lox": `import { twice } from "lib.lox";
+print twice(host());
+`,
+		"lib.lox": `export fun twice(s) {
+  return s + s;
+}
+`,
+	})
+
+	var b strings.Builder
+	l := NewLox(&b)
+	l.SetModuleReader(os.ReadFile)
+	l.DefineNative("host", 0, func(args []Value) (Value, error) {
+		return "go", nil
+	})
+	require.NoError(t, l.RunFile(context.TODO(), filepath.Join(dir, "main.lox")))
+	require.Equal(t, "gogo\n", b.String())
+
+	// natives replace globals of the same name
+	l.DefineNative("host", 0, func(args []Value) (Value, error) {
+		return "lox", nil
+	})
+	b.Reset()
+	require.NoError(t, l.Run(context.TODO(), []byte("print twice(host());")))
+	require.Equal(t, "loxlox\n", b.String())
+
+	// errors and invalid values are reported at the call site
+	l.DefineNative("fail", 0, func(args []Value) (Value, error) {
+		return nil, errors.New("host failure")
+	})
+	l.DefineNative("bad", 0, func(args []Value) (Value, error) {
+		return 42, nil
+	})
+	l.DefineNative("shout", 1, func(args []Value) (Value, error) {
+		return args[0].(string) + "!", nil
+	})
+	for code, want := range map[string]string{
+		"fail();":   "host failure",
+		"bad();":    "native function returned int, which is not a Lox value",
+		"shout(1);": "native function panicked: interface conversion: interface {} is float64, not string",
+	} {
+		err := l.Run(context.TODO(), []byte(code))
+		require.Error(t, err)
+
+		diags := diag.Collect(err, diag.PhaseRuntime)
+		require.Len(t, diags, 1)
+		require.Equal(t, want, diags[0].Message)
+		require.Equal(t, "1:"+strconv.Itoa(len(code)-1), diags[0].Span.String())
+	}
+
+	// panics in functions come with the stack trace
+	err := l.Run(context.TODO(), []byte("fun f() {\n  shout(nil);\n}\nf();"))
+	var runtimeErr *interpreter.RuntimeError
+	require.ErrorAs(t, err, &runtimeErr)
+	require.Equal(t, "2:12", runtimeErr.Token.Span().String())
+	require.Len(t, runtimeErr.Trace, 1)
+	require.Equal(t, "f", runtimeErr.Trace[0].Function)
+
+	require.Error(t, l.SetGlobal("n", 1))
+}
+
+type bindUser struct {
+	Name    string
+	Friends []string
+	Address struct {
+		City string
+	}
+}
+
+func (u *bindUser) Greet(greeting string) string {
+	return greeting + ", " + u.Name
+}
+
+func ExampleLox_Bind() {
+	const code = `
+var user = fetchUser(1);
+print user.Name;
+print user.Greet("hello");
+print user.Friends;
+user.Address.City = "Paris";
+print "${type(user)} in ${user.Address.City}";
+print sum([1, 2, 3]);
+print scale({"x": 1, "y": 2}, 10);
+`
+
+	users := map[int]*bindUser{1: {Name: "Ann", Friends: []string{"Bob"}}}
+
+	l := NewLox(os.Stdout)
+	err := l.Bind("fetchUser", func(id int) (*bindUser, error) {
+		user, ok := users[id]
+		if !ok {
+			return nil, fmt.Errorf("user %d not found", id)
+		}
+		return user, nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	err = l.Bind("sum", func(numbers ...float64) (sum float64) {
+		for _, n := range numbers {
+			sum += n
+		}
+		return
+	})
+	if err != nil {
+		panic(err)
+	}
+	err = l.Bind("scale", func(point map[string]float64, factor float64) map[string]float64 {
+		return map[string]float64{"x": point["x"] * factor}
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = l.Run(context.TODO(), []byte(code))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(users[1].Address.City)
+	// Output:
+	// Ann
+	// hello, Ann
+	// ["Bob"]
+	// instance in Paris
+	// 6
+	// {"x": 10}
+	// Paris
+}
+
+type bindTeam struct {
+	Lead bindUser
+}
+
+func Test_Lox_Bind_sharing(t *testing.T) {
+	const code = `
+print team == team;
+print team.Lead == team.Lead;
+print team.Lead.Address == team.Lead.Address;
+print team == team.Lead;
+print team.Lead == other;
+var lead = team.Lead;
+lead.Friends.push("Cid");
+print lead.Friends;
+lead.Friends = ["Cid"];
+print lead.Friends;
+`
+
+	team := &bindTeam{Lead: bindUser{Name: "Ann", Friends: []string{"Bob"}}}
+
+	var b strings.Builder
+	l := NewLox(&b)
+	require.NoError(t, l.Bind("team", team))
+	require.NoError(t, l.Bind("other", &bindUser{Name: "Ann"}))
+	require.NoError(t, l.Run(context.TODO(), []byte(code)))
+	require.Equal(t, `true
+true
+true
+false
+false
+["Bob"]
+["Cid"]
+`, b.String())
+	require.Equal(t, []string{"Cid"}, team.Lead.Friends)
+}
+
+func Test_Lox_Bind_errors(t *testing.T) {
+	l := NewLox(io.Discard)
+	require.NoError(t, l.Bind("fetchUser", func(id int) (*bindUser, error) {
+		if id != 1 {
+			return nil, fmt.Errorf("user %d not found", id)
+		}
+		return &bindUser{Name: "Ann"}, nil
+	}))
+	require.NoError(t, l.Bind("config", struct{ Port int }{Port: 80}))
+	require.NoError(t, l.Bind("first", func(names []string) string {
+		return names[0]
+	}))
+
+	tests := []struct {
+		name       string
+		code       string
+		wantCode   diag.Code
+		wantSpan   string
+		wantReason string
+	}{
+		{
+			name:       "argument conversion",
+			code:       `fetchUser("1");`,
+			wantCode:   diag.CodeRuntime,
+			wantSpan:   "1:14",
+			wantReason: "argument at index 0: can not convert string into int",
+		},
+		{
+			name:       "returned error",
+			code:       `fetchUser(2);`,
+			wantCode:   diag.CodeRuntime,
+			wantSpan:   "1:12",
+			wantReason: "user 2 not found",
+		},
+		{
+			name:       "panic",
+			code:       `first([]);`,
+			wantCode:   diag.CodeRuntime,
+			wantSpan:   "1:9",
+			wantReason: "Go function panicked: runtime error: index out of range [0] with length 0",
+		},
+		{
+			name:       "undefined field",
+			code:       `print config.Host;`,
+			wantCode:   diag.CodeUndefinedProperty,
+			wantSpan:   "1:14",
+			wantReason: `undefined property "Host" of struct { Port int }`,
+		},
+		{
+			name:       "field assignment",
+			code:       `config.Port = "80";`,
+			wantCode:   diag.CodeOperandType,
+			wantSpan:   "1:8",
+			wantReason: `assigning field "Port": can not convert string into int`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.Run(context.TODO(), []byte(tt.code))
+			require.Error(t, err)
+
+			diags := diag.Collect(err, diag.PhaseRuntime)
+			require.Len(t, diags, 1)
+			require.Equal(t, tt.wantCode, diags[0].Code)
+			require.Equal(t, tt.wantSpan, diags[0].Span.String())
+			require.Equal(t, tt.wantReason, diags[0].Message)
+		})
+	}
+
+	require.Error(t, l.Bind("channel", make(chan int)))
+}
+
+func ExampleLox_Call() {
+	const code = `
+fun onEvent(event) {
+  return "handled ${event["name"]}";
+}
+
+class Counter {
+  init() {
+    this.count = 0;
+  }
+  add(n) {
+    this.count = this.count + n;
+    return this.count;
+  }
+}
+
+var counter = Counter();
+
+fun words(s) {
+  return String.split(s, " ");
+}
+`
+
+	l := NewLox(os.Stdout)
+	err := l.Run(context.TODO(), []byte(code))
+	if err != nil {
+		panic(err)
+	}
+
+	result, err := l.Call(context.TODO(), "onEvent", map[string]string{"name": "click"})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(result)
+
+	counter, _ := l.GetGlobal("counter")
+	add, err := l.Method(counter, "add")
+	if err != nil {
+		panic(err)
+	}
+	for n := 1; n <= 3; n++ {
+		result, err = add.Call(context.TODO(), n)
+		if err != nil {
+			panic(err)
+		}
+	}
+	fmt.Println(result, add.Arity())
+
+	result, err = l.Call(context.TODO(), "words", "to be or not")
+	if err != nil {
+		panic(err)
+	}
+	var words []string
+	err = ConvertValue(result, &words)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(len(words), words[3])
+	// Output:
+	// handled click
+	// 6 1
+	// 4 not
+}
+
+func Test_Lox_Call_errors(t *testing.T) {
+	l := NewLox(io.Discard)
+	require.NoError(t, l.Run(context.TODO(), []byte(`var answer = 42;
+fun fail(reason) {
+  throw reason;
+}
+fun callback(f) {
+  return "got ${host(f)}";
+}
+`)))
+
+	_, err := l.Call(context.TODO(), "nope")
+	require.EqualError(t, err, `undefined global "nope"`)
+
+	_, err = l.Call(context.TODO(), "answer")
+	require.EqualError(t, err, `global "answer" is not callable`)
+
+	_, err = l.Call(context.TODO(), "fail")
+	diags := diag.Collect(err, diag.PhaseRuntime)
+	require.Len(t, diags, 1)
+	require.Equal(t, diag.CodeArityMismatch, diags[0].Code)
+
+	_, err = l.Call(context.TODO(), "fail", "boom")
+	var runtimeErr *interpreter.RuntimeError
+	require.ErrorAs(t, err, &runtimeErr)
+	require.Equal(t, diag.CodeUncaughtException, runtimeErr.Code)
+	require.Equal(t, "boom", runtimeErr.Value)
+	require.Equal(t, "fail", runtimeErr.Trace[0].Function)
+
+	_, err = l.Method(l, "m")
+	require.Error(t, err)
+
+	// Lox can be called back inside natives
+	l.DefineNative("host", 1, func(args []Value) (Value, error) {
+		return (&Function{lox: l, callable: args[0].(interpreter.Callable)}).Call(context.TODO(), 2)
+	})
+	require.NoError(t, l.Run(context.TODO(), []byte(`print callback(fun (n) { return n * 21; });`)))
+	result, err := l.Call(context.TODO(), "callback", func(n int) int { return n + 1 })
+	require.NoError(t, err)
+	require.Equal(t, "got 3", result)
+}
+
+func Test_Lox_limits(t *testing.T) {
+	tests := []struct {
+		name      string
+		limits    interpreter.Limits
+		code      string
+		wantCause error
+		wantCode  diag.Code
+	}{
+		{
+			name:      "steps",
+			limits:    interpreter.Limits{MaxSteps: 1000},
+			code:      "var n = 0;\nwhile (true) {\n  n = n + 1;\n}",
+			wantCause: &interpreter.StepLimitError{Limit: 1000},
+			wantCode:  diag.CodeStepLimit,
+		},
+		{
+			name:      "call depth",
+			limits:    interpreter.Limits{MaxCallDepth: 50},
+			code:      "fun f(n) {\n  return f(n + 1);\n}\nf(0);",
+			wantCause: &interpreter.CallDepthError{Limit: 50},
+			wantCode:  diag.CodeCallDepthLimit,
+		},
+		{
+			name:      "default call depth",
+			code:      "fun f(n) {\n  return f(n + 1);\n}\nf(0);",
+			wantCause: &interpreter.CallDepthError{Limit: interpreter.DefaultMaxCallDepth},
+			wantCode:  diag.CodeCallDepthLimit,
+		},
+		{
+			name:      "memory of strings",
+			limits:    interpreter.Limits{MaxMemory: 1 << 16},
+			code:      "var s = \"\";\nwhile (true) {\n  s = s + \"abcd\";\n}",
+			wantCause: &interpreter.MemoryLimitError{Limit: 1 << 16},
+			wantCode:  diag.CodeMemoryLimit,
+		},
+		{
+			name:      "memory of lists",
+			limits:    interpreter.Limits{MaxMemory: 1 << 16},
+			code:      "var l = [];\nwhile (true) {\n  l.push([1, 2, 3]);\n}",
+			wantCause: &interpreter.MemoryLimitError{Limit: 1 << 16},
+			wantCode:  diag.CodeMemoryLimit,
+		},
+		{
+			name:      "not caught by scripts",
+			limits:    interpreter.Limits{MaxSteps: 1000},
+			code:      "try {\n  while (true) {}\n} catch (e) {\n  print e;\n}",
+			wantCause: &interpreter.StepLimitError{Limit: 1000},
+			wantCode:  diag.CodeStepLimit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			l := NewLox(&b)
+			l.SetLimits(tt.limits)
+			err := l.Run(context.TODO(), []byte(tt.code))
+			require.Error(t, err)
+			require.Empty(t, b.String())
+
+			switch tt.wantCause.(type) {
+			case *interpreter.StepLimitError:
+				var cause *interpreter.StepLimitError
+				require.ErrorAs(t, err, &cause)
+				require.Equal(t, tt.wantCause, cause)
+			case *interpreter.CallDepthError:
+				var cause *interpreter.CallDepthError
+				require.ErrorAs(t, err, &cause)
+				require.Equal(t, tt.wantCause, cause)
+			case *interpreter.MemoryLimitError:
+				var cause *interpreter.MemoryLimitError
+				require.ErrorAs(t, err, &cause)
+				require.Equal(t, tt.wantCause, cause)
+			}
+
+			diags := diag.Collect(err, diag.PhaseRuntime)
+			require.Len(t, diags, 1)
+			require.Equal(t, tt.wantCode, diags[0].Code)
+			require.NotZero(t, diags[0].Span.Line)
+		})
+	}
+}
+
+func Test_Lox_limits_per_run(t *testing.T) {
+	var b strings.Builder
+	l := NewLox(&b)
+	l.SetLimits(interpreter.Limits{MaxSteps: 100})
+	require.NoError(t, l.Run(context.TODO(), []byte("fun add(a, b) {\n  return a + b;\n}")))
+
+	for n := 0; n < 50; n++ {
+		_, err := l.Call(context.TODO(), "add", n, 1)
+		require.NoError(t, err)
+	}
+
+	// deep recursion only shows both ends of the stack trace
+	l.SetLimits(interpreter.Limits{MaxCallDepth: 100})
+	code := []byte("fun f() {\n  f();\n}\nf();")
+	err := l.Run(context.TODO(), code)
+	require.Error(t, err)
+	require.NoError(t, RenderError(&b, "deep.lox", code, err))
+	require.Contains(t, b.String(), "  ... 80 more\n")
+	require.Len(t, strings.Split(strings.TrimSpace(b.String()), "\n"), 4+1+10+1+10+1)
+}
+
+func Test_Lox_nested_run(t *testing.T) {
+	const code = `
+fun f() {
+  var local = "outer";
+  run("var inner = 2;");
+  print local;
+  run("print inner;");
+  return local / 2;
+}
+f();
+`
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var b strings.Builder
+	l := NewLox(&b)
+	l.DefineNative("run", 1, func(args []Value) (Value, error) {
+		return nil, l.Run(context.TODO(), []byte(args[0].(string)))
+	})
+	err := l.Run(ctx, []byte(code))
+	require.Error(t, err)
+	// globals of the nested run are of the script, not of f
+	require.Equal(t, "outer\n2\n", b.String())
+
+	// the call stack of the outer run is kept
+	var runtimeErr *interpreter.RuntimeError
+	require.ErrorAs(t, err, &runtimeErr)
+	require.Equal(t, diag.CodeOperandType, runtimeErr.Code)
+	require.Len(t, runtimeErr.Trace, 1)
+	require.Equal(t, "f", runtimeErr.Trace[0].Function)
+
+	// the context of the outer run is restored
+	l.DefineNative("stop", 0, func(args []Value) (Value, error) {
+		cancel()
+		return nil, l.Run(context.TODO(), []byte(`print "nested";`))
+	})
+	b.Reset()
+	err = l.Run(ctx, []byte("stop();\nprint \"outer\";"))
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, "nested\n", b.String())
+
+	// usage of the nested runs counts in the outer one
+	l.SetLimits(interpreter.Limits{MaxSteps: 100})
+	l.DefineNative("steps", 0, func(args []Value) (Value, error) {
+		return nil, l.Run(context.TODO(), []byte("var n = 1;\nn = n + 1;"))
+	})
+	require.NoError(t, l.Run(context.TODO(), []byte("steps();")))
+	err = l.Run(context.TODO(), []byte("for (var k = 0; k < 20; k = k + 1) {\n  steps();\n}"))
+	require.ErrorAs(t, err, &runtimeErr)
+	require.Equal(t, diag.CodeStepLimit, runtimeErr.Code)
+}
+
+func Test_Lox_cancellation(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+	}{
+		{
+			name: "recursion",
+			code: "fun fib(n) {\n  if (n < 2) return n;\n  return fib(n - 1) + fib(n - 2);\n}\nprint fib(100);",
+		},
+		{
+			name: "sleep",
+			code: "sleep(60000);\nprint \"woke up\";",
+		},
+		{
+			name: "not caught by scripts",
+			code: "try {\n  sleep(60000);\n} catch (e) {\n  print e;\n}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			var b strings.Builder
+			l := NewLox(&b)
+			start := time.Now()
+			err := l.Run(ctx, []byte(tt.code))
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+			require.Less(t, time.Since(start), 5*time.Second)
+			require.Empty(t, b.String())
+		})
+	}
+
+	// nothing runs once cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var b strings.Builder
+	l := NewLox(&b)
+	err := l.Run(ctx, []byte(`print "hello";`))
+	require.ErrorIs(t, err, context.Canceled)
+	require.Empty(t, b.String())
+}
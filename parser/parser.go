@@ -1,12 +1,12 @@
 package parser
 
 import (
-	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 
 	"github.com/nanmu42/bluelox/ast"
+	"github.com/nanmu42/bluelox/diag"
 	"github.com/nanmu42/bluelox/token"
 )
 
@@ -19,24 +19,29 @@ type Parser struct {
 }
 
 type ParsingErr struct {
-	errs []error
+	diags diag.List
+}
+
+// Diagnostics returns every parsing error in source order.
+func (p *ParsingErr) Diagnostics() diag.List {
+	return p.diags
 }
 
 func (p *ParsingErr) Error() string {
-	length := len(p.errs)
+	length := len(p.diags)
 
 	if length == 0 {
 		return "parsing error with 0 detail, it's likely that there is a problem in implementation"
 	}
 
 	if length == 1 {
-		return fmt.Sprintf("parsing error: %s", p.errs[0])
+		return fmt.Sprintf("parsing error: %s", p.diags[0])
 	}
 
 	var b strings.Builder
 	_, _ = fmt.Fprintf(&b, "got %d parsing error(s):\n", length)
 
-	for index, err := range p.errs {
+	for index, err := range p.diags {
 		b.WriteString(strconv.Itoa(index+1) + ". ")
 		b.WriteString(err.Error())
 		b.WriteRune('\n')
@@ -61,20 +66,20 @@ func NewParser(tokens []*token.Token) *Parser {
 // Otherwise, more than one error may appear,
 // and expr can not be considered valid.
 func (p *Parser) Parse() (stmts []ast.Statement, err error) {
-	var errs []error
+	var diags diag.List
 
 	for !p.isAtEnd() {
 		stmt, stmtErr := p.declaration()
 		if stmtErr != nil {
-			errs = append(errs, stmtErr)
+			diags = append(diags, diag.FromError(diag.PhaseParse, stmtErr))
 			p.synchronize()
 			continue
 		}
 		stmts = append(stmts, stmt)
 	}
 
-	if len(errs) > 0 {
-		err = &ParsingErr{errs}
+	if len(diags) > 0 {
+		err = &ParsingErr{diags}
 		return
 	}
 
@@ -86,6 +91,9 @@ func (p *Parser) Parse() (stmts []ast.Statement, err error) {
 // | ifStmt
 // | printStmt
 // | returnStmt
+// | loopJumpStmt
+// | throwStmt
+// | tryStmt
 // | whileStmt
 // | block ;
 func (p *Parser) statement() (stmt ast.Statement, err error) {
@@ -101,18 +109,31 @@ func (p *Parser) statement() (stmt ast.Statement, err error) {
 	if p.match(token.Return) {
 		return p.returnStmt()
 	}
+	if p.match(token.Break, token.Continue) {
+		return p.loopJumpStmt()
+	}
+	if p.match(token.Throw) {
+		return p.throwStmt()
+	}
+	if p.match(token.Try) {
+		return p.tryStmt()
+	}
 	if p.match(token.While) {
 		return p.whileStmt()
 	}
 	if p.match(token.LeftBrace) {
-		var innerStmts []ast.Statement
+		leftBrace := p.previous()
 
+		var innerStmts []ast.Statement
 		innerStmts, err = p.block()
 		if err != nil {
 			return
 		}
 
-		stmt = &ast.BlockStmt{Stmts: innerStmts}
+		stmt = &ast.BlockStmt{
+			Stmts: innerStmts,
+			Range: leftBrace.Span().Through(p.previous().Span()),
+		}
 		return
 	}
 
@@ -145,6 +166,7 @@ func (p *Parser) equality() (expr ast.Expression, err error) {
 			Left:     expr,
 			Operator: operator,
 			Right:    right,
+			Range:    expr.Span().Through(right.Span()),
 		}
 	}
 
@@ -172,6 +194,7 @@ func (p *Parser) comparison() (expr ast.Expression, err error) {
 			Left:     expr,
 			Operator: operator,
 			Right:    right,
+			Range:    expr.Span().Through(right.Span()),
 		}
 	}
 
@@ -201,6 +224,15 @@ func (p *Parser) check(tokenType token.Type) bool {
 	return p.peek().Type == tokenType
 }
 
+// checkNext is like check, but looks at the token after the current one.
+func (p *Parser) checkNext(tokenType token.Type) bool {
+	if p.isAtEnd() || p.current+1 >= len(p.tokens) {
+		return false
+	}
+
+	return p.tokens[p.current+1].Type == tokenType
+}
+
 func (p *Parser) advance() *token.Token {
 	if !p.isAtEnd() {
 		p.current++
@@ -237,20 +269,21 @@ func (p *Parser) term() (expr ast.Expression, err error) {
 			Left:     expr,
 			Operator: operator,
 			Right:    right,
+			Range:    expr.Span().Through(right.Span()),
 		}
 	}
 
 	return
 }
 
-// factor → unary ( ( "/" | "*" ) unary )* ;
+// factor → unary ( ( "/" | "*" | "%" ) unary )* ;
 func (p *Parser) factor() (expr ast.Expression, err error) {
 	expr, err = p.unary()
 	if err != nil {
 		return
 	}
 
-	for p.match(token.Slash, token.Star) {
+	for p.match(token.Slash, token.Star, token.Percent) {
 		var (
 			operator = p.previous()
 			right    ast.Expression
@@ -263,13 +296,14 @@ func (p *Parser) factor() (expr ast.Expression, err error) {
 			Left:     expr,
 			Operator: operator,
 			Right:    right,
+			Range:    expr.Span().Through(right.Span()),
 		}
 	}
 
 	return
 }
 
-// unary → ( "!" | "-" ) unary | call ;
+// unary → ( "!" | "-" ) unary | exponent ;
 func (p *Parser) unary() (expr ast.Expression, err error) {
 	if p.match(token.Bang, token.Minus) {
 		var (
@@ -283,32 +317,78 @@ func (p *Parser) unary() (expr ast.Expression, err error) {
 		expr = &ast.UnaryExpr{
 			Operator: operator,
 			Right:    right,
+			Range:    operator.Span().Through(right.Span()),
 		}
 		return
 	}
 
-	return p.call()
+	return p.exponent()
+}
+
+// exponent → call ( "**" unary )? ;
+//
+// "**" is right-associative, and binds tighter than unary operators on its left,
+// so that -2 ** 2 is -(2 ** 2).
+func (p *Parser) exponent() (expr ast.Expression, err error) {
+	expr, err = p.call()
+	if err != nil {
+		return
+	}
+
+	if !p.match(token.StarStar) {
+		return
+	}
+
+	operator := p.previous()
+	right, err := p.unary()
+	if err != nil {
+		return
+	}
+
+	expr = &ast.BinaryExpr{
+		Left:     expr,
+		Operator: operator,
+		Right:    right,
+		Range:    expr.Span().Through(right.Span()),
+	}
+	return
 }
 
 // primary → → "true" | "false" | "nil" | "this"
-//               | NUMBER | STRING | IDENTIFIER | "(" expression ")"
-//               | "super" "." IDENTIFIER ;
+//
+//	| NUMBER | STRING | IDENTIFIER | "(" expression ")"
+//	| "super" "." IDENTIFIER | lambda | list | map | interpolation ;
 func (p *Parser) primary() (expr ast.Expression, err error) {
 	if p.match(token.Number, token.String) {
-		expr = &ast.LiteralExpr{Value: p.previous().Literal}
+		expr = &ast.LiteralExpr{
+			Value: p.previous().Literal,
+			Range: p.previous().Span(),
+		}
 		return
 	}
+	if p.match(token.Interpolation) {
+		return p.interpolation()
+	}
 
 	if p.match(token.True) {
-		expr = &ast.LiteralExpr{Value: true}
+		expr = &ast.LiteralExpr{
+			Value: true,
+			Range: p.previous().Span(),
+		}
 		return
 	}
 	if p.match(token.False) {
-		expr = &ast.LiteralExpr{Value: false}
+		expr = &ast.LiteralExpr{
+			Value: false,
+			Range: p.previous().Span(),
+		}
 		return
 	}
 	if p.match(token.Nil) {
-		expr = &ast.LiteralExpr{Value: nil}
+		expr = &ast.LiteralExpr{
+			Value: nil,
+			Range: p.previous().Span(),
+		}
 		return
 	}
 	if p.match(token.Super) {
@@ -328,35 +408,56 @@ func (p *Parser) primary() (expr ast.Expression, err error) {
 		expr = &ast.SuperExpr{
 			Keyword: keyword,
 			Method:  method,
+			Range:   keyword.Span().Through(method.Span()),
 		}
 		return
 	}
+	if p.match(token.Fun) {
+		return p.lambda()
+	}
+	if p.match(token.LeftBracket) {
+		return p.list()
+	}
+	if p.match(token.LeftBrace) {
+		return p.mapLiteral()
+	}
 	if p.match(token.This) {
-		expr = &ast.ThisExpr{Keyword: p.previous()}
+		expr = &ast.ThisExpr{
+			Keyword: p.previous(),
+			Range:   p.previous().Span(),
+		}
 		return
 	}
 	if p.match(token.Identifier) {
-		expr = &ast.VariableExpr{Name: p.previous()}
+		expr = &ast.VariableExpr{
+			Name:  p.previous(),
+			Range: p.previous().Span(),
+		}
 		return
 	}
 
 	if p.match(token.LeftParen) {
+		leftParen := p.previous()
 		expr, err = p.expression()
 		if err != nil {
 			return
 		}
-		_, err = p.consume(token.RightParen)
+		var rightParen *token.Token
+		rightParen, err = p.consume(token.RightParen)
 		if err != nil {
 			err = fmt.Errorf("expected ')' after expression: %w", err)
 			return
 		}
 
-		expr = &ast.GroupingExpr{Expr: expr}
+		expr = &ast.GroupingExpr{
+			Expr:  expr,
+			Range: leftParen.Span().Through(rightParen.Span()),
+		}
 		return
 	}
 
 	unexpected := p.peek()
-	err = fmt.Errorf("parsing primary: unexpected token %s %q at line %d", unexpected.Type, unexpected.Lexeme, unexpected.Line)
+	err = diag.Errorf(diag.PhaseParse, diag.CodeUnexpectedToken, unexpected.Span(), "parsing primary: unexpected token %s %q", unexpected.Type, unexpected.Lexeme)
 	return
 }
 
@@ -367,7 +468,7 @@ func (p *Parser) consume(wantType token.Type) (consumed *token.Token, err error)
 	}
 
 	peek := p.peek()
-	err = fmt.Errorf("want token type %s, got %s at line %d", wantType, peek.Type, peek.Line)
+	err = diag.Errorf(diag.PhaseParse, diag.CodeUnexpectedToken, peek.Span(), "want token type %s, got %s", wantType, peek.Type)
 	return
 }
 
@@ -382,7 +483,9 @@ func (p *Parser) synchronize() {
 
 		switch p.peek().Type {
 		case token.Class, token.Fun, token.Var, token.For,
-			token.If, token.While, token.Print, token.Return:
+			token.If, token.While, token.Print, token.Return,
+			token.Break, token.Continue, token.Throw, token.Try,
+			token.Import, token.Export:
 			return
 		}
 
@@ -392,18 +495,22 @@ func (p *Parser) synchronize() {
 
 // printStmt → "print" expression ";" ;
 func (p *Parser) printStmt() (stmt ast.Statement, err error) {
+	keyword := p.previous()
 	value, err := p.expression()
 	if err != nil {
 		return
 	}
 
-	_, err = p.consume(token.Semicolon)
+	semicolon, err := p.consume(token.Semicolon)
 	if err != nil {
 		err = fmt.Errorf("expected ';' after value: %w", err)
 		return
 	}
 
-	stmt = &ast.PrintStmt{Expr: value}
+	stmt = &ast.PrintStmt{
+		Expr:  value,
+		Range: keyword.Span().Through(semicolon.Span()),
+	}
 	return
 }
 
@@ -414,23 +521,35 @@ func (p *Parser) exprStmt() (stmt ast.Statement, err error) {
 		return
 	}
 
-	_, err = p.consume(token.Semicolon)
+	semicolon, err := p.consume(token.Semicolon)
 	if err != nil {
 		err = fmt.Errorf("expected ';' after value: %w", err)
 		return
 	}
 
-	stmt = &ast.ExprStmt{Expr: value}
+	stmt = &ast.ExprStmt{
+		Expr:  value,
+		Range: value.Span().Through(semicolon.Span()),
+	}
 	return
 }
 
-// declaration → classDecl | funDecl | varDecl | statement ;
+// declaration → classDecl | funDecl | varDecl | exportDecl | importStmt | statement ;
 func (p *Parser) declaration() (stmt ast.Statement, err error) {
+	if p.match(token.Export) {
+		return p.exportDecl()
+	}
+	if p.match(token.Import) {
+		return p.importStmt()
+	}
+
 	if p.match(token.Class) {
 		return p.classDecl()
 	}
 
-	if p.match(token.Fun) {
+	// "fun" followed by "(" starts an anonymous function expression
+	if p.check(token.Fun) && p.checkNext(token.Identifier) {
+		p.advance()
 		return p.function("function")
 	}
 
@@ -442,20 +561,207 @@ func (p *Parser) declaration() (stmt ast.Statement, err error) {
 }
 
 // function → IDENTIFIER "(" parameters? ")" block ;
+// getter   → IDENTIFIER block ;
+//
+// getters are only allowed in class bodies.
 func (p *Parser) function(kind string) (stmt ast.Statement, err error) {
+	// methods start with their names, functions start with "fun",
+	// and static methods start with "class"
+	start := p.peek()
+	if previous := p.previous(); previous.Type == token.Fun || previous.Type == token.Class {
+		start = previous
+	}
+
 	name, err := p.consume(token.Identifier)
 	if err != nil {
 		err = fmt.Errorf("expected %s name: %w", kind, err)
 		return
 	}
 
+	if kind != "function" && p.match(token.LeftBrace) {
+		var body []ast.Statement
+		body, err = p.block()
+		if err != nil {
+			return
+		}
+
+		stmt = &ast.FunctionStmt{
+			Name:     name,
+			Body:     body,
+			IsGetter: true,
+			Range:    start.Span().Through(p.previous().Span()),
+		}
+		return
+	}
+
 	_, err = p.consume(token.LeftParen)
 	if err != nil {
 		err = fmt.Errorf("expected '(' after %s name: %w", kind, err)
 		return
 	}
 
-	var parameters []*token.Token
+	parameters, body, err := p.functionBody(kind)
+	if err != nil {
+		return
+	}
+
+	stmt = &ast.FunctionStmt{
+		Name:   name,
+		Params: parameters,
+		Body:   body,
+		Range:  start.Span().Through(p.previous().Span()),
+	}
+	return
+}
+
+// list → "[" ( expression ( "," expression )* ","? )? "]" ;
+func (p *Parser) list() (expr ast.Expression, err error) {
+	leftBracket := p.previous()
+
+	var elements []ast.Expression
+	for !p.check(token.RightBracket) {
+		var element ast.Expression
+		element, err = p.expression()
+		if err != nil {
+			return
+		}
+		elements = append(elements, element)
+
+		if !p.match(token.Comma) {
+			break
+		}
+	}
+
+	rightBracket, err := p.consume(token.RightBracket)
+	if err != nil {
+		err = fmt.Errorf("expected ']' after list elements: %w", err)
+		return
+	}
+
+	expr = &ast.ListExpr{
+		Elements: elements,
+		Range:    leftBracket.Span().Through(rightBracket.Span()),
+	}
+	return
+}
+
+// map → "{" ( entry ( "," entry )* ","? )? "}" ;
+// entry → expression ":" expression ;
+func (p *Parser) mapLiteral() (expr ast.Expression, err error) {
+	leftBrace := p.previous()
+
+	var keys, values []ast.Expression
+	for !p.check(token.RightBrace) {
+		var key, value ast.Expression
+		key, err = p.expression()
+		if err != nil {
+			return
+		}
+
+		_, err = p.consume(token.Colon)
+		if err != nil {
+			err = fmt.Errorf("expected ':' after map key: %w", err)
+			return
+		}
+
+		value, err = p.expression()
+		if err != nil {
+			return
+		}
+
+		keys = append(keys, key)
+		values = append(values, value)
+
+		if !p.match(token.Comma) {
+			break
+		}
+	}
+
+	rightBrace, err := p.consume(token.RightBrace)
+	if err != nil {
+		err = fmt.Errorf("expected '}' after map entries: %w", err)
+		return
+	}
+
+	expr = &ast.MapExpr{
+		Brace:  leftBrace,
+		Keys:   keys,
+		Values: values,
+		Range:  leftBrace.Span().Through(rightBrace.Span()),
+	}
+	return
+}
+
+// interpolation → INTERPOLATION expression ( INTERPOLATION expression )* STRING ;
+func (p *Parser) interpolation() (expr ast.Expression, err error) {
+	start := p.previous()
+
+	var parts []ast.Expression
+	for {
+		// empty parts like "${a}${b}" are left out
+		if literal := p.previous(); literal.Literal != "" {
+			parts = append(parts, &ast.LiteralExpr{
+				Value: literal.Literal,
+				Range: literal.Span(),
+			})
+		}
+
+		if p.previous().Type == token.String {
+			break
+		}
+
+		// strings after embedded expressions start with "}"
+		if next := p.peek(); (next.Type == token.Interpolation || next.Type == token.String) && strings.HasPrefix(next.Lexeme, "}") {
+			err = diag.Errorf(diag.PhaseParse, diag.CodeUnexpectedToken, next.Span(), "expected expression in '${}'")
+			return
+		}
+
+		var embedded ast.Expression
+		embedded, err = p.expression()
+		if err != nil {
+			return
+		}
+		parts = append(parts, embedded)
+
+		if !p.match(token.Interpolation, token.String) {
+			err = diag.Errorf(diag.PhaseParse, diag.CodeUnexpectedToken, p.peek().Span(), "expected '}' after embedded expression, got %s %q", p.peek().Type, p.peek().Lexeme)
+			return
+		}
+	}
+
+	expr = &ast.InterpolationExpr{
+		Parts: parts,
+		Range: start.Span().Through(p.previous().Span()),
+	}
+	return
+}
+
+// lambda → "fun" "(" parameters? ")" block ;
+func (p *Parser) lambda() (expr ast.Expression, err error) {
+	keyword := p.previous()
+	_, err = p.consume(token.LeftParen)
+	if err != nil {
+		err = fmt.Errorf("expected '(' after 'fun': %w", err)
+		return
+	}
+
+	parameters, body, err := p.functionBody("function")
+	if err != nil {
+		return
+	}
+
+	expr = &ast.FunctionExpr{
+		Keyword: keyword,
+		Params:  parameters,
+		Body:    body,
+		Range:   keyword.Span().Through(p.previous().Span()),
+	}
+	return
+}
+
+// functionBody parses what follows "(" in a function,
+// that is, parameters? ")" block
+func (p *Parser) functionBody(kind string) (parameters []*token.Token, body []ast.Statement, err error) {
 	if !p.check(token.RightParen) {
 		var firstParam *token.Token
 		firstParam, err = p.consume(token.Identifier)
@@ -467,7 +773,7 @@ func (p *Parser) function(kind string) (stmt ast.Statement, err error) {
 
 		for p.match(token.Comma) {
 			if len(parameters) >= maxFuncArgCounts {
-				err = fmt.Errorf("can't have more than %d parameters", maxFuncArgCounts)
+				err = diag.Errorf(diag.PhaseParse, diag.CodeTooManyArguments, p.peek().Span(), "can't have more than %d parameters", maxFuncArgCounts)
 				return
 			}
 
@@ -493,21 +799,97 @@ func (p *Parser) function(kind string) (stmt ast.Statement, err error) {
 		return
 	}
 
-	body, err := p.block()
+	body, err = p.block()
+	return
+}
+
+// exportDecl → "export" ( classDecl | funDecl | varDecl ) ;
+func (p *Parser) exportDecl() (stmt ast.Statement, err error) {
+	keyword := p.previous()
+
+	var decl ast.Statement
+	switch {
+	case p.match(token.Class):
+		decl, err = p.classDecl()
+	case p.check(token.Fun) && p.checkNext(token.Identifier):
+		p.advance()
+		decl, err = p.function("function")
+	case p.match(token.Var):
+		decl, err = p.varDecl()
+	default:
+		err = diag.Errorf(diag.PhaseParse, diag.CodeUnexpectedToken, p.peek().Span(), "expected class, function or variable declaration after 'export'")
+	}
 	if err != nil {
 		return
 	}
 
-	stmt = &ast.FunctionStmt{
-		Name:   name,
-		Params: parameters,
-		Body:   body,
+	stmt = &ast.ExportStmt{
+		Keyword: keyword,
+		Decl:    decl,
+		Range:   keyword.Span().Through(decl.Span()),
+	}
+	return
+}
+
+// importStmt → "import" ( "{" IDENTIFIER ( "," IDENTIFIER )* "}" "from" )? STRING ";" ;
+//
+// "from" is not a keyword, but an identifier here.
+func (p *Parser) importStmt() (stmt ast.Statement, err error) {
+	keyword := p.previous()
+
+	var names []*token.Token
+	if p.match(token.LeftBrace) {
+		for {
+			var name *token.Token
+			name, err = p.consume(token.Identifier)
+			if err != nil {
+				err = fmt.Errorf("expected name to import: %w", err)
+				return
+			}
+			names = append(names, name)
+
+			if !p.match(token.Comma) {
+				break
+			}
+		}
+
+		_, err = p.consume(token.RightBrace)
+		if err != nil {
+			err = fmt.Errorf("expected '}' after imported names: %w", err)
+			return
+		}
+
+		if next := p.peek(); next.Type != token.Identifier || next.Lexeme != "from" {
+			err = diag.Errorf(diag.PhaseParse, diag.CodeUnexpectedToken, next.Span(), "expected 'from' after imported names, got %s %q", next.Type, next.Lexeme)
+			return
+		}
+		p.advance()
+	}
+
+	path, err := p.consume(token.String)
+	if err != nil {
+		err = fmt.Errorf("expected module path: %w", err)
+		return
+	}
+
+	semicolon, err := p.consume(token.Semicolon)
+	if err != nil {
+		err = fmt.Errorf("expected ';' after module path: %w", err)
+		return
+	}
+
+	stmt = &ast.ImportStmt{
+		Keyword: keyword,
+		Names:   names,
+		Path:    path,
+		Range:   keyword.Span().Through(semicolon.Span()),
 	}
 	return
 }
 
 // varDecl → "var" IDENTIFIER ( "=" expression )? ";" ;
 func (p *Parser) varDecl() (stmt ast.Statement, err error) {
+	keyword := p.previous()
 	name, err := p.consume(token.Identifier)
 	if err != nil {
 		err = fmt.Errorf("expected a variable name: %w", err)
@@ -522,7 +904,7 @@ func (p *Parser) varDecl() (stmt ast.Statement, err error) {
 		}
 	}
 
-	_, err = p.consume(token.Semicolon)
+	semicolon, err := p.consume(token.Semicolon)
 	if err != nil {
 		err = fmt.Errorf("expected ';' after variable declaration: %w", err)
 		return
@@ -531,22 +913,33 @@ func (p *Parser) varDecl() (stmt ast.Statement, err error) {
 	stmt = &ast.VarStmt{
 		Name:        name,
 		Initializer: initializer,
+		Range:       keyword.Span().Through(semicolon.Span()),
 	}
 
 	return
 }
 
-// assignment → ( call "." )? IDENTIFIER "=" assignment | logic_or ;
+// assignment → ( call "." )? IDENTIFIER assign_op assignment
+//
+//	| call "[" expression "]" assign_op assignment | conditional ;
+//
+// assign_op → "=" | "+=" | "-=" | "*=" | "/=" ;
 func (p *Parser) assignment() (expr ast.Expression, err error) {
-	expr, err = p.or()
+	expr, err = p.conditional()
 	if err != nil {
 		return
 	}
 
-	if !p.match(token.Equal) {
+	if !p.match(token.Equal, token.PlusEqual, token.MinusEqual, token.StarEqual, token.SlashEqual) {
 		return
 	}
 
+	// nil for plain assignments
+	var operator *token.Token
+	if p.previous().Type != token.Equal {
+		operator = p.previous()
+	}
+
 	var value ast.Expression
 	value, err = p.assignment()
 	if err != nil {
@@ -556,26 +949,75 @@ func (p *Parser) assignment() (expr ast.Expression, err error) {
 
 	if get, ok := expr.(*ast.GetExpr); ok {
 		expr = &ast.SetExpr{
-			Object: get.Object,
-			Name:   get.Name,
-			Value:  value,
+			Object:   get.Object,
+			Name:     get.Name,
+			Operator: operator,
+			Value:    value,
+			Range:    expr.Span().Through(value.Span()),
+		}
+	} else if get, ok := expr.(*ast.IndexGetExpr); ok {
+		expr = &ast.IndexSetExpr{
+			Object:   get.Object,
+			Bracket:  get.Bracket,
+			Index:    get.Index,
+			Operator: operator,
+			Value:    value,
+			Range:    expr.Span().Through(value.Span()),
 		}
 	} else {
 		name, ok := expr.(*ast.VariableExpr)
 		if !ok {
-			err = errors.New("invalid assignment target")
+			err = diag.Errorf(diag.PhaseParse, diag.CodeInvalidAssignmentTarget, expr.Span(), "invalid assignment target")
 			return
 		}
 
 		expr = &ast.AssignExpr{
-			Name:  name.Name,
-			Value: value,
+			Name:     name.Name,
+			Operator: operator,
+			Value:    value,
+			Range:    expr.Span().Through(value.Span()),
 		}
 	}
 
 	return
 }
 
+// conditional → logic_or ( "?" expression ":" conditional )? ;
+func (p *Parser) conditional() (expr ast.Expression, err error) {
+	expr, err = p.or()
+	if err != nil {
+		return
+	}
+
+	if !p.match(token.Question) {
+		return
+	}
+
+	thenBranch, err := p.expression()
+	if err != nil {
+		return
+	}
+
+	_, err = p.consume(token.Colon)
+	if err != nil {
+		err = fmt.Errorf("expected ':' after then branch of conditional expression: %w", err)
+		return
+	}
+
+	elseBranch, err := p.conditional()
+	if err != nil {
+		return
+	}
+
+	expr = &ast.ConditionalExpr{
+		Condition:  expr,
+		ThenBranch: thenBranch,
+		ElseBranch: elseBranch,
+		Range:      expr.Span().Through(elseBranch.Span()),
+	}
+	return
+}
+
 func (p *Parser) block() (stmts []ast.Statement, err error) {
 	for !p.check(token.RightBrace) {
 		var innerStmt ast.Statement
@@ -597,6 +1039,7 @@ func (p *Parser) block() (stmts []ast.Statement, err error) {
 }
 
 func (p *Parser) ifStmt() (stmt ast.Statement, err error) {
+	keyword := p.previous()
 	_, err = p.consume(token.LeftParen)
 	if err != nil {
 		err = fmt.Errorf("expected '(' after 'if': %w", err)
@@ -628,6 +1071,7 @@ func (p *Parser) ifStmt() (stmt ast.Statement, err error) {
 		Condition:  condition,
 		ThenBranch: thenBranch,
 		ElseBranch: elseBranch,
+		Range:      keyword.Span().Through(p.previous().Span()),
 	}
 
 	return
@@ -653,6 +1097,7 @@ func (p *Parser) or() (expr ast.Expression, err error) {
 			Left:     expr,
 			Operator: operator,
 			Right:    right,
+			Range:    expr.Span().Through(right.Span()),
 		}
 	}
 
@@ -679,6 +1124,7 @@ func (p *Parser) and() (expr ast.Expression, err error) {
 			Left:     expr,
 			Operator: operator,
 			Right:    right,
+			Range:    expr.Span().Through(right.Span()),
 		}
 	}
 
@@ -687,6 +1133,7 @@ func (p *Parser) and() (expr ast.Expression, err error) {
 
 // whileStmt      → "while" "(" expression ")" statement ;
 func (p *Parser) whileStmt() (stmt ast.Statement, err error) {
+	keyword := p.previous()
 	_, err = p.consume(token.LeftParen)
 	if err != nil {
 		err = fmt.Errorf("expected '(' after 'while': %w", err)
@@ -712,14 +1159,17 @@ func (p *Parser) whileStmt() (stmt ast.Statement, err error) {
 	stmt = &ast.WhileStmt{
 		Condition: condition,
 		Body:      body,
+		Range:     keyword.Span().Through(body.Span()),
 	}
 	return
 }
 
 // forStmt        → "for" "(" ( varDecl | exprStmt | ";" )
-//                 expression? ";"
-//                 expression? ")" statement ;
+//
+//	expression? ";"
+//	expression? ")" statement ;
 func (p *Parser) forStmt() (stmt ast.Statement, err error) {
+	keyword := p.previous()
 	_, err = p.consume(token.LeftParen)
 	if err != nil {
 		err = fmt.Errorf("expected '(' after 'for': %w", err)
@@ -748,7 +1198,10 @@ func (p *Parser) forStmt() (stmt ast.Statement, err error) {
 			return
 		}
 	} else {
-		condition = &ast.LiteralExpr{Value: true}
+		condition = &ast.LiteralExpr{
+			Value: true,
+			Range: p.peek().Span(),
+		}
 	}
 	_, err = p.consume(token.Semicolon)
 	if err != nil {
@@ -774,18 +1227,15 @@ func (p *Parser) forStmt() (stmt ast.Statement, err error) {
 	if err != nil {
 		return
 	}
-	if increment != nil {
-		body = &ast.BlockStmt{
-			Stmts: []ast.Statement{
-				body,
-				&ast.ExprStmt{Expr: increment},
-			},
-		}
-	}
+	// the desugared nodes locate at the whole for statement
+	forRange := keyword.Span().Through(body.Span())
 
+	// increment is kept aside from body, so that it still runs on continue
 	stmt = &ast.WhileStmt{
 		Condition: condition,
 		Body:      body,
+		Increment: increment,
+		Range:     forRange,
 	}
 
 	if initializer != nil {
@@ -794,13 +1244,14 @@ func (p *Parser) forStmt() (stmt ast.Statement, err error) {
 				initializer,
 				stmt,
 			},
+			Range: forRange,
 		}
 	}
 
 	return
 }
 
-// call  → primary ( "(" arguments? ")" | "." IDENTIFIER )* ;
+// call  → primary ( "(" arguments? ")" | "." IDENTIFIER | "[" expression "]" )* ;
 func (p *Parser) call() (expr ast.Expression, err error) {
 	expr, err = p.primary()
 	if err != nil {
@@ -823,6 +1274,28 @@ func (p *Parser) call() (expr ast.Expression, err error) {
 			expr = &ast.GetExpr{
 				Object: expr,
 				Name:   name,
+				Range:  expr.Span().Through(name.Span()),
+			}
+		} else if p.match(token.LeftBracket) {
+			bracket := p.previous()
+
+			var index ast.Expression
+			index, err = p.expression()
+			if err != nil {
+				return
+			}
+
+			var rightBracket *token.Token
+			rightBracket, err = p.consume(token.RightBracket)
+			if err != nil {
+				err = fmt.Errorf("expected ']' after index: %w", err)
+				return
+			}
+			expr = &ast.IndexGetExpr{
+				Object:  expr,
+				Bracket: bracket,
+				Index:   index,
+				Range:   expr.Span().Through(rightBracket.Span()),
 			}
 		} else {
 			break
@@ -854,7 +1327,7 @@ func (p *Parser) finishCall(callee ast.Expression) (expr ast.Expression, err err
 				return
 			}
 			if argSeq >= maxFuncArgCounts {
-				err = fmt.Errorf("can't have more than %d arguments", maxFuncArgCounts)
+				err = diag.Errorf(diag.PhaseParse, diag.CodeTooManyArguments, arg.Span(), "can't have more than %d arguments", maxFuncArgCounts)
 				return
 			}
 
@@ -872,6 +1345,7 @@ func (p *Parser) finishCall(callee ast.Expression) (expr ast.Expression, err err
 		Callee:    callee,
 		Paren:     paren,
 		Arguments: arguments,
+		Range:     callee.Span().Through(paren.Span()),
 	}
 	return
 }
@@ -886,7 +1360,7 @@ func (p *Parser) returnStmt() (stmt ast.Statement, err error) {
 		}
 	}
 
-	_, err = p.consume(token.Semicolon)
+	semicolon, err := p.consume(token.Semicolon)
 	if err != nil {
 		err = fmt.Errorf("expected ';' after return value: %w", err)
 		return
@@ -894,13 +1368,133 @@ func (p *Parser) returnStmt() (stmt ast.Statement, err error) {
 	stmt = &ast.ReturnStmt{
 		Keyword: keyword,
 		Value:   value,
+		Range:   keyword.Span().Through(semicolon.Span()),
+	}
+
+	return
+}
+
+// throwStmt → "throw" expression ";" ;
+func (p *Parser) throwStmt() (stmt ast.Statement, err error) {
+	keyword := p.previous()
+	value, err := p.expression()
+	if err != nil {
+		return
+	}
+
+	semicolon, err := p.consume(token.Semicolon)
+	if err != nil {
+		err = fmt.Errorf("expected ';' after thrown value: %w", err)
+		return
+	}
+
+	stmt = &ast.ThrowStmt{
+		Keyword: keyword,
+		Value:   value,
+		Range:   keyword.Span().Through(semicolon.Span()),
+	}
+	return
+}
+
+// tryStmt → "try" block ( "catch" "(" IDENTIFIER ")" block )? ( "finally" block )? ;
+//
+// at least one of catch and finally is required.
+func (p *Parser) tryStmt() (stmt ast.Statement, err error) {
+	keyword := p.previous()
+	_, err = p.consume(token.LeftBrace)
+	if err != nil {
+		err = fmt.Errorf("expected '{' after 'try': %w", err)
+		return
+	}
+	body, err := p.block()
+	if err != nil {
+		return
+	}
+
+	var (
+		catchName   *token.Token
+		catchBody   []ast.Statement
+		finallyBody []ast.Statement
+	)
+	if p.match(token.Catch) {
+		_, err = p.consume(token.LeftParen)
+		if err != nil {
+			err = fmt.Errorf("expected '(' after 'catch': %w", err)
+			return
+		}
+		catchName, err = p.consume(token.Identifier)
+		if err != nil {
+			err = fmt.Errorf("expected exception name: %w", err)
+			return
+		}
+		_, err = p.consume(token.RightParen)
+		if err != nil {
+			err = fmt.Errorf("expected ')' after exception name: %w", err)
+			return
+		}
+		_, err = p.consume(token.LeftBrace)
+		if err != nil {
+			err = fmt.Errorf("expected '{' before catch body: %w", err)
+			return
+		}
+		catchBody, err = p.block()
+		if err != nil {
+			return
+		}
+	}
+
+	if p.match(token.Finally) {
+		_, err = p.consume(token.LeftBrace)
+		if err != nil {
+			err = fmt.Errorf("expected '{' after 'finally': %w", err)
+			return
+		}
+		finallyBody, err = p.block()
+		if err != nil {
+			return
+		}
+	} else if catchName == nil {
+		err = diag.Errorf(diag.PhaseParse, diag.CodeUnexpectedToken, p.peek().Span(), "expected 'catch' or 'finally' after try block")
+		return
 	}
 
+	stmt = &ast.TryStmt{
+		Body:        body,
+		CatchName:   catchName,
+		CatchBody:   catchBody,
+		FinallyBody: finallyBody,
+		Range:       keyword.Span().Through(p.previous().Span()),
+	}
 	return
 }
 
-// classDecl  → "class" IDENTIFIER ( "<" IDENTIFIER )? "{" function* "}" ;
+// loopJumpStmt → ( "break" | "continue" ) ";" ;
+func (p *Parser) loopJumpStmt() (stmt ast.Statement, err error) {
+	keyword := p.previous()
+	semicolon, err := p.consume(token.Semicolon)
+	if err != nil {
+		err = fmt.Errorf("expected ';' after '%s': %w", keyword.Lexeme, err)
+		return
+	}
+
+	if keyword.Type == token.Break {
+		stmt = &ast.BreakStmt{
+			Keyword: keyword,
+			Range:   keyword.Span().Through(semicolon.Span()),
+		}
+		return
+	}
+
+	stmt = &ast.ContinueStmt{
+		Keyword: keyword,
+		Range:   keyword.Span().Through(semicolon.Span()),
+	}
+	return
+}
+
+// classDecl  → "class" IDENTIFIER ( "<" IDENTIFIER )? "{" ( "class"? ( function | getter ) )* "}" ;
 func (p *Parser) classDecl() (stmt ast.Statement, err error) {
+	keyword := p.previous()
 	name, err := p.consume(token.Identifier)
 	if err != nil {
 		err = fmt.Errorf("expected class name: %w", err)
@@ -916,7 +1510,8 @@ func (p *Parser) classDecl() (stmt ast.Statement, err error) {
 		}
 
 		superClass = &ast.VariableExpr{
-			Name: p.previous(),
+			Name:  p.previous(),
+			Range: p.previous().Span(),
 		}
 	}
 
@@ -925,9 +1520,18 @@ func (p *Parser) classDecl() (stmt ast.Statement, err error) {
 		err = fmt.Errorf("expected '{' before class body: %w", err)
 		return
 	}
-	var methods []*ast.FunctionStmt
+	var methods, staticMethods []*ast.FunctionStmt
 	for !p.isAtEnd() && !p.check(token.RightBrace) {
 		var method ast.Statement
+		if p.match(token.Class) {
+			method, err = p.function("static method")
+			if err != nil {
+				return
+			}
+			staticMethods = append(staticMethods, method.(*ast.FunctionStmt))
+			continue
+		}
+
 		method, err = p.function("method")
 		if err != nil {
 			return
@@ -935,16 +1539,18 @@ func (p *Parser) classDecl() (stmt ast.Statement, err error) {
 		methods = append(methods, method.(*ast.FunctionStmt))
 	}
 
-	_, err = p.consume(token.RightBrace)
+	rightBrace, err := p.consume(token.RightBrace)
 	if err != nil {
 		err = fmt.Errorf("expected '}' after class body: %w", err)
 		return
 	}
 
 	stmt = &ast.ClassStmt{
-		Name:       name,
-		SuperClass: superClass,
-		Methods:    methods,
+		Name:          name,
+		SuperClass:    superClass,
+		Methods:       methods,
+		StaticMethods: staticMethods,
+		Range:         keyword.Span().Through(rightBrace.Span()),
 	}
 
 	return
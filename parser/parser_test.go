@@ -6,7 +6,10 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"github.com/nanmu42/bluelox/ast"
+	"github.com/nanmu42/bluelox/scanner"
 	"github.com/nanmu42/bluelox/token"
 )
 
@@ -66,8 +69,9 @@ func TestParser_Parse(t *testing.T) {
 						Literal: nil,
 						Line:    1,
 					},
-					Right: &ast.LiteralExpr{Value: 5},
-				}},
+					Right: &ast.LiteralExpr{Value: 5, Range: line1},
+					Range: line1,
+				}, Range: line1},
 			},
 			wantErr: nil,
 		},
@@ -107,8 +111,9 @@ func TestParser_Parse(t *testing.T) {
 						Literal: nil,
 						Line:    1,
 					},
-					Right: &ast.LiteralExpr{Value: nil},
-				}}},
+					Right: &ast.LiteralExpr{Value: nil, Range: line1},
+					Range: line1,
+				}, Range: line1}},
 			wantErr: nil,
 		},
 		{
@@ -147,8 +152,9 @@ func TestParser_Parse(t *testing.T) {
 						Literal: nil,
 						Line:    1,
 					},
-					Right: &ast.LiteralExpr{Value: nil},
-				}}},
+					Right: &ast.LiteralExpr{Value: nil, Range: line1},
+					Range: line1,
+				}, Range: line1}},
 			wantErr: nil,
 		},
 		{
@@ -212,7 +218,8 @@ func TestParser_Parse(t *testing.T) {
 							Literal: nil,
 							Line:    1,
 						},
-						Right: &ast.LiteralExpr{Value: 123},
+						Right: &ast.LiteralExpr{Value: 123, Range: line1},
+						Range: line1,
 					},
 					Operator: &token.Token{
 						Type:    token.Star,
@@ -220,8 +227,9 @@ func TestParser_Parse(t *testing.T) {
 						Literal: nil,
 						Line:    1,
 					},
-					Right: &ast.GroupingExpr{Expr: &ast.LiteralExpr{Value: 45.67}},
-				}}},
+					Right: &ast.GroupingExpr{Expr: &ast.LiteralExpr{Value: 45.67, Range: line1}, Range: line1},
+					Range: line1,
+				}, Range: line1}},
 			wantErr: nil,
 		},
 	}
@@ -239,6 +247,9 @@ func TestParser_Parse(t *testing.T) {
 	}
 }
 
+// line1 is where nodes locate, as tokens in tests only come with line numbers.
+var line1 = token.Span{Line: 1}
+
 func jsonify(v interface{}) string {
 	marshaled, err := json.Marshal(v)
 	if err != nil {
@@ -248,3 +259,122 @@ func jsonify(v interface{}) string {
 
 	return string(marshaled)
 }
+
+func TestParser_spans(t *testing.T) {
+	const source = `print 1 + (2 * x);
+fun add(a, b) {
+  return a + b;
+}
+class A < B { get() { return this.v; } }
+for (var i = 0; i < 3; i = i + 1) print i;
+var f = fun (x) { return x; };`
+
+	tokens, err := scanner.NewScanner([]byte(source)).ScanTokens()
+	require.NoError(t, err)
+	stmts, err := NewParser(tokens).Parse()
+	require.NoError(t, err)
+
+	spanned := func(node interface{ Span() token.Span }) string {
+		span := node.Span()
+		return source[span.Offset:span.End]
+	}
+
+	require.Len(t, stmts, 5)
+	require.Equal(t, "print 1 + (2 * x);", spanned(stmts[0]))
+	require.Equal(t, "1 + (2 * x)", spanned(stmts[0].(*ast.PrintStmt).Expr))
+	require.Equal(t, "(2 * x)", spanned(stmts[0].(*ast.PrintStmt).Expr.(*ast.BinaryExpr).Right))
+	require.Equal(t, "fun add(a, b) {\n  return a + b;\n}", spanned(stmts[1]))
+	require.Equal(t, "return a + b;", spanned(stmts[1].(*ast.FunctionStmt).Body[0]))
+	require.Equal(t, "class A < B { get() { return this.v; } }", spanned(stmts[2]))
+	require.Equal(t, "get() { return this.v; }", spanned(stmts[2].(*ast.ClassStmt).Methods[0]))
+	require.Equal(t, "for (var i = 0; i < 3; i = i + 1) print i;", spanned(stmts[3]))
+	require.Equal(t, "fun (x) { return x; }", spanned(stmts[4].(*ast.VarStmt).Initializer))
+
+	span := stmts[1].(*ast.FunctionStmt).Body[0].Span()
+	require.Equal(t, 3, span.Line)
+	require.Equal(t, 3, span.Column)
+}
+
+func TestParser_precedence(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		// check returns the sub-expression which shows how the expression is grouped
+		check func(expr ast.Expression) ast.Expression
+		want  string
+	}{
+		{
+			name:   "exponent is right-associative",
+			source: "2 ** 3 ** 2",
+			check: func(expr ast.Expression) ast.Expression {
+				return expr.(*ast.BinaryExpr).Right
+			},
+			want: "3 ** 2",
+		},
+		{
+			name:   "exponent binds tighter than unary on its left",
+			source: "-2 ** 2",
+			check: func(expr ast.Expression) ast.Expression {
+				return expr.(*ast.UnaryExpr).Right
+			},
+			want: "2 ** 2",
+		},
+		{
+			name:   "modulo is a factor",
+			source: "1 + 5 % 3",
+			check: func(expr ast.Expression) ast.Expression {
+				return expr.(*ast.BinaryExpr).Right
+			},
+			want: "5 % 3",
+		},
+		{
+			name:   "conditional is right-associative",
+			source: "a ? b : c ? d : e",
+			check: func(expr ast.Expression) ast.Expression {
+				return expr.(*ast.ConditionalExpr).ElseBranch
+			},
+			want: "c ? d : e",
+		},
+		{
+			name:   "conditional binds looser than or",
+			source: "a or b ? c : d",
+			check: func(expr ast.Expression) ast.Expression {
+				return expr.(*ast.ConditionalExpr).Condition
+			},
+			want: "a or b",
+		},
+		{
+			name:   "compound assignment takes a conditional",
+			source: "x.y += a ? 1 : 2",
+			check: func(expr ast.Expression) ast.Expression {
+				set := expr.(*ast.SetExpr)
+				require.Equal(t, token.PlusEqual, set.Operator.Type)
+				return set.Value
+			},
+			want: "a ? 1 : 2",
+		},
+		{
+			name:   "plain assignment has no operator",
+			source: "xs[0] = 1",
+			check: func(expr ast.Expression) ast.Expression {
+				set := expr.(*ast.IndexSetExpr)
+				require.Nil(t, set.Operator)
+				return set.Value
+			},
+			want: "1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := tt.source + ";"
+			tokens, err := scanner.NewScanner([]byte(source)).ScanTokens()
+			require.NoError(t, err)
+			stmts, err := NewParser(tokens).Parse()
+			require.NoError(t, err)
+			require.Len(t, stmts, 1)
+
+			span := tt.check(stmts[0].(*ast.ExprStmt).Expr).Span()
+			require.Equal(t, tt.want, source[span.Offset:span.End])
+		})
+	}
+}
@@ -8,4 +8,6 @@ const (
 	FuncTypeFunc
 	FuncTypeInitializer
 	FuncTypeMethod
+	FuncTypeGetter
+	FuncTypeStaticMethod
 )
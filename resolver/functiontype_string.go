@@ -12,11 +12,13 @@ func _() {
 	_ = x[FuncTypeFunc-1]
 	_ = x[FuncTypeInitializer-2]
 	_ = x[FuncTypeMethod-3]
+	_ = x[FuncTypeGetter-4]
+	_ = x[FuncTypeStaticMethod-5]
 }
 
-const _FunctionType_name = "FuncTypeNoneFuncTypeFuncFuncTypeInitializerFuncTypeMethod"
+const _FunctionType_name = "FuncTypeNoneFuncTypeFuncFuncTypeInitializerFuncTypeMethodFuncTypeGetterFuncTypeStaticMethod"
 
-var _FunctionType_index = [...]uint8{0, 12, 24, 43, 57}
+var _FunctionType_index = [...]uint8{0, 12, 24, 43, 57, 71, 91}
 
 func (i FunctionType) String() string {
 	if i < 0 || i >= FunctionType(len(_FunctionType_index)-1) {
@@ -1,10 +1,12 @@
 package resolver
 
 import (
-	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/nanmu42/bluelox/ast"
+	"github.com/nanmu42/bluelox/diag"
 	"github.com/nanmu42/bluelox/interpreter"
 	"github.com/nanmu42/bluelox/token"
 )
@@ -22,6 +24,50 @@ type Resolver struct {
 	scopes          scopes // used as a stack
 	currentFunction FunctionType
 	currentClass    ClassType
+	// how many loops enclose the current statement in the current function
+	loopDepth int
+
+	// semantic errors met so far
+	diags diag.List
+}
+
+// ResolvingErr holds every semantic error found in a program.
+type ResolvingErr struct {
+	diags diag.List
+}
+
+// Diagnostics returns every semantic error in source order.
+func (r *ResolvingErr) Diagnostics() diag.List {
+	return r.diags
+}
+
+func (r *ResolvingErr) Error() string {
+	length := len(r.diags)
+
+	if length == 0 {
+		return "resolving error with 0 detail, it's likely that there is a problem in implementation"
+	}
+
+	if length == 1 {
+		return fmt.Sprintf("resolving error: %s", r.diags[0])
+	}
+
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "got %d resolving error(s):\n", length)
+
+	for index, err := range r.diags {
+		b.WriteString(strconv.Itoa(index+1) + ". ")
+		b.WriteString(err.Error())
+		b.WriteRune('\n')
+
+		if index >= 9 {
+			b.WriteString("too many errors, more contents omitted...")
+			b.WriteRune('\n')
+			return b.String()
+		}
+	}
+
+	return b.String()
 }
 
 func NewResolver(interpreter *interpreter.Interpreter) *Resolver {
@@ -32,11 +78,7 @@ func NewResolver(interpreter *interpreter.Interpreter) *Resolver {
 }
 
 func (r *Resolver) VisitBlockStmt(v *ast.BlockStmt) (err error) {
-	r.beginScope()
-	defer r.endScope()
-
-	err = r.ResolveStmts(v.Stmts)
-	return
+	return r.resolveBlock(v.Stmts)
 }
 
 func (r *Resolver) VisitExprStmt(v *ast.ExprStmt) (err error) {
@@ -44,13 +86,10 @@ func (r *Resolver) VisitExprStmt(v *ast.ExprStmt) (err error) {
 }
 
 func (r *Resolver) VisitFunctionStmt(v *ast.FunctionStmt) (err error) {
-	err = r.declare(v.Name)
-	if err != nil {
-		return
-	}
+	r.declare(v.Name)
 	r.define(v.Name)
 
-	err = r.resolveFunction(v, FuncTypeFunc)
+	err = r.resolveFunction(v.Params, v.Body, FuncTypeFunc)
 	return
 }
 
@@ -78,13 +117,11 @@ func (r *Resolver) VisitPrintStmt(v *ast.PrintStmt) (err error) {
 
 func (r *Resolver) VisitReturnStmt(v *ast.ReturnStmt) (err error) {
 	if r.currentFunction == FuncTypeNone {
-		err = errors.New("can't return from top-level code")
-		return
+		r.report(diag.CodeTopLevelReturn, v.Keyword.Span(), "can't return from top-level code")
 	}
 	if v.Value != nil {
 		if r.currentFunction == FuncTypeInitializer {
-			err = fmt.Errorf("can't return a value from an initializer: %s", v.Keyword)
-			return
+			r.report(diag.CodeReturnFromInitializer, v.Value.Span(), "can't return a value from an initializer")
 		}
 		return r.resolveExpr(v.Value)
 	}
@@ -93,10 +130,7 @@ func (r *Resolver) VisitReturnStmt(v *ast.ReturnStmt) (err error) {
 }
 
 func (r *Resolver) VisitVarStmt(v *ast.VarStmt) (err error) {
-	err = r.declare(v.Name)
-	if err != nil {
-		return
-	}
+	r.declare(v.Name)
 	if v.Initializer != nil {
 		err = r.resolveExpr(v.Initializer)
 		if err != nil {
@@ -113,7 +147,78 @@ func (r *Resolver) VisitWhileStmt(v *ast.WhileStmt) (err error) {
 		return
 	}
 
-	return r.resolveStmt(v.Body)
+	r.loopDepth++
+	defer func() {
+		r.loopDepth--
+	}()
+
+	err = r.resolveStmt(v.Body)
+	if err != nil {
+		return
+	}
+
+	if v.Increment != nil {
+		err = r.resolveExpr(v.Increment)
+	}
+
+	return
+}
+
+func (r *Resolver) VisitBreakStmt(v *ast.BreakStmt) (err error) {
+	if r.loopDepth == 0 {
+		r.report(diag.CodeOutsideLoop, v.Keyword.Span(), "can't use 'break' outside of a loop")
+	}
+
+	return
+}
+
+func (r *Resolver) VisitContinueStmt(v *ast.ContinueStmt) (err error) {
+	if r.loopDepth == 0 {
+		r.report(diag.CodeOutsideLoop, v.Keyword.Span(), "can't use 'continue' outside of a loop")
+	}
+
+	return
+}
+
+func (r *Resolver) VisitExportStmt(v *ast.ExportStmt) (err error) {
+	if !r.scopes.IsEmpty() {
+		r.report(diag.CodeNotTopLevel, v.Keyword.Span(), "can only export at top level")
+	}
+
+	return r.resolveStmt(v.Decl)
+}
+
+func (r *Resolver) VisitImportStmt(v *ast.ImportStmt) (err error) {
+	if !r.scopes.IsEmpty() {
+		r.report(diag.CodeNotTopLevel, v.Keyword.Span(), "can only import at top level")
+	}
+
+	return
+}
+
+func (r *Resolver) VisitThrowStmt(v *ast.ThrowStmt) (err error) {
+	return r.resolveExpr(v.Value)
+}
+
+func (r *Resolver) VisitTryStmt(v *ast.TryStmt) (err error) {
+	err = r.resolveBlock(v.Body)
+	if err != nil {
+		return
+	}
+
+	if v.CatchName != nil {
+		r.beginScope()
+		r.declare(v.CatchName)
+		r.define(v.CatchName)
+		err = r.resolveStmts(v.CatchBody)
+		r.endScope()
+		if err != nil {
+			return
+		}
+	}
+
+	err = r.resolveBlock(v.FinallyBody)
+	return
 }
 
 func (r *Resolver) VisitAssignExpr(v *ast.AssignExpr) (result interface{}, err error) {
@@ -152,6 +257,26 @@ func (r *Resolver) VisitCallExpr(v *ast.CallExpr) (result interface{}, err error
 	return
 }
 
+func (r *Resolver) VisitConditionalExpr(v *ast.ConditionalExpr) (result interface{}, err error) {
+	err = r.resolveExpr(v.Condition)
+	if err != nil {
+		return
+	}
+
+	err = r.resolveExpr(v.ThenBranch)
+	if err != nil {
+		return
+	}
+
+	err = r.resolveExpr(v.ElseBranch)
+	return
+}
+
+func (r *Resolver) VisitFunctionExpr(v *ast.FunctionExpr) (result interface{}, err error) {
+	err = r.resolveFunction(v.Params, v.Body, FuncTypeFunc)
+	return
+}
+
 func (r *Resolver) VisitGetExpr(v *ast.GetExpr) (result interface{}, err error) {
 	err = r.resolveExpr(v.Object)
 	return
@@ -162,6 +287,69 @@ func (r *Resolver) VisitGroupingExpr(v *ast.GroupingExpr) (result interface{}, e
 	return
 }
 
+func (r *Resolver) VisitIndexGetExpr(v *ast.IndexGetExpr) (result interface{}, err error) {
+	err = r.resolveExpr(v.Object)
+	if err != nil {
+		return
+	}
+
+	err = r.resolveExpr(v.Index)
+	return
+}
+
+func (r *Resolver) VisitIndexSetExpr(v *ast.IndexSetExpr) (result interface{}, err error) {
+	err = r.resolveExpr(v.Value)
+	if err != nil {
+		return
+	}
+
+	err = r.resolveExpr(v.Object)
+	if err != nil {
+		return
+	}
+
+	err = r.resolveExpr(v.Index)
+	return
+}
+
+func (r *Resolver) VisitInterpolationExpr(v *ast.InterpolationExpr) (result interface{}, err error) {
+	for _, part := range v.Parts {
+		err = r.resolveExpr(part)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (r *Resolver) VisitListExpr(v *ast.ListExpr) (result interface{}, err error) {
+	for _, element := range v.Elements {
+		err = r.resolveExpr(element)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (r *Resolver) VisitMapExpr(v *ast.MapExpr) (result interface{}, err error) {
+	for index := range v.Keys {
+		err = r.resolveExpr(v.Keys[index])
+		if err != nil {
+			return
+		}
+
+		err = r.resolveExpr(v.Values[index])
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
 func (r *Resolver) VisitLiteralExpr(v *ast.LiteralExpr) (result interface{}, err error) {
 	return
 }
@@ -188,7 +376,7 @@ func (r *Resolver) VisitSetExpr(v *ast.SetExpr) (result interface{}, err error)
 
 func (r *Resolver) VisitThisExpr(v *ast.ThisExpr) (result interface{}, err error) {
 	if r.currentClass == ClassTypeNone {
-		err = fmt.Errorf("can't use 'this' outside of a class: %s", v.Keyword)
+		r.report(diag.CodeThisOutsideClass, v.Keyword.Span(), "can't use 'this' outside of a class")
 		return
 	}
 
@@ -203,10 +391,9 @@ func (r *Resolver) VisitUnaryExpr(v *ast.UnaryExpr) (result interface{}, err err
 
 func (r *Resolver) VisitVariableExpr(v *ast.VariableExpr) (result interface{}, err error) {
 	if !r.scopes.IsEmpty() {
-		initialized, ok := r.scopes.Peek()[v.Name.Lexeme]
-		if ok && !initialized {
-			err = fmt.Errorf("can't read local variable %q in its own initializer", v.Name.Lexeme)
-			return
+		variable, ok := r.scopes.Peek()[v.Name.Lexeme]
+		if ok && !variable.defined {
+			r.report(diag.CodeReadInOwnInitializer, v.Name.Span(), "can't read local variable %q in its own initializer", v.Name.Lexeme)
 		}
 	}
 
@@ -221,19 +408,14 @@ func (r *Resolver) VisitClassStmt(v *ast.ClassStmt) (err error) {
 		r.currentClass = enclosingClass
 	}()
 
-	err = r.declare(v.Name)
-	if err != nil {
-		return
-	}
-
+	r.declare(v.Name)
 	r.define(v.Name)
 
 	if v.SuperClass != nil {
 		r.currentClass = ClassTypeSubclass
 
 		if v.SuperClass.Name.Lexeme == v.Name.Lexeme {
-			err = fmt.Errorf("the class %q can't inherit from itself, at line %d", v.Name.Lexeme, v.Name.Line)
-			return
+			r.report(diag.CodeSelfInheritance, v.SuperClass.Span(), "the class %q can't inherit from itself", v.Name.Lexeme)
 		}
 
 		err = r.resolveExpr(v.SuperClass)
@@ -243,21 +425,35 @@ func (r *Resolver) VisitClassStmt(v *ast.ClassStmt) (err error) {
 
 		r.beginScope()
 		defer r.endScope()
-		r.scopes.Peek()["super"] = true
+		r.scopes.Peek()["super"] = &binding{defined: true}
 	}
 
 	r.beginScope()
 	defer r.endScope()
-	r.scopes.Peek()["this"] = true
+	r.scopes.Peek()["this"] = &binding{defined: true}
 
 	for _, method := range v.Methods {
 		var funcType FunctionType
-		if method.Name.Lexeme == "init" {
+		switch {
+		case method.Name.Lexeme == "init":
 			funcType = FuncTypeInitializer
-		} else {
+			if method.IsGetter {
+				r.report(diag.CodeGetterInitializer, method.Name.Span(), "an initializer can't be a getter")
+			}
+		case method.IsGetter:
+			funcType = FuncTypeGetter
+		default:
 			funcType = FuncTypeMethod
 		}
-		err = r.resolveFunction(method, funcType)
+		err = r.resolveFunction(method.Params, method.Body, funcType)
+		if err != nil {
+			return
+		}
+	}
+
+	// "this" in static methods is the class itself
+	for _, method := range v.StaticMethods {
+		err = r.resolveFunction(method.Params, method.Body, FuncTypeStaticMethod)
 		if err != nil {
 			return
 		}
@@ -268,10 +464,10 @@ func (r *Resolver) VisitClassStmt(v *ast.ClassStmt) (err error) {
 
 func (r *Resolver) VisitSuperExpr(v *ast.SuperExpr) (result interface{}, err error) {
 	if r.currentClass == ClassTypeNone {
-		err = fmt.Errorf("can't use 'super' outside of a class: %s", v)
+		r.report(diag.CodeSuperOutsideClass, v.Span(), "can't use 'super' outside of a class")
 		return
 	} else if r.currentClass != ClassTypeSubclass {
-		err = fmt.Errorf("can't use 'super' in a class with no superclass: %s", v)
+		r.report(diag.CodeSuperWithoutSuperclass, v.Span(), "can't use 'super' in a class with no superclass")
 		return
 	}
 
@@ -279,7 +475,32 @@ func (r *Resolver) VisitSuperExpr(v *ast.SuperExpr) (result interface{}, err err
 	return
 }
 
+// ResolveStmts resolves variables in stmts for the interpreter.
+//
+// Resolving goes on after semantic errors,
+// err is a *ResolvingErr holding all of them.
 func (r *Resolver) ResolveStmts(stmts []ast.Statement) (err error) {
+	r.diags = nil
+
+	err = r.resolveStmts(stmts)
+	if err != nil {
+		r.diags = append(r.diags, diag.FromError(diag.PhaseResolve, err))
+	}
+
+	if len(r.diags) > 0 {
+		err = &ResolvingErr{r.diags}
+		return
+	}
+
+	return
+}
+
+// report records a semantic error, and resolving goes on.
+func (r *Resolver) report(code diag.Code, span token.Span, format string, args ...interface{}) {
+	r.diags = append(r.diags, diag.Errorf(diag.PhaseResolve, code, span, format, args...))
+}
+
+func (r *Resolver) resolveStmts(stmts []ast.Statement) (err error) {
 	for _, stmt := range stmts {
 		err = r.resolveStmt(stmt)
 		if err != nil {
@@ -290,6 +511,15 @@ func (r *Resolver) ResolveStmts(stmts []ast.Statement) (err error) {
 	return
 }
 
+// resolveBlock resolves stmts in a new scope, like a block statement.
+func (r *Resolver) resolveBlock(stmts []ast.Statement) (err error) {
+	r.beginScope()
+	defer r.endScope()
+
+	err = r.resolveStmts(stmts)
+	return
+}
+
 func (r *Resolver) resolveStmt(stmt ast.Statement) error {
 	return stmt.Accept(r)
 }
@@ -300,26 +530,31 @@ func (r *Resolver) resolveExpr(expr ast.Expression) (err error) {
 }
 
 func (r *Resolver) beginScope() {
-	r.scopes.Push(make(map[string]bool))
+	r.scopes.Push(make(map[string]*binding))
 }
 
 func (r *Resolver) endScope() {
 	r.scopes.Pop()
 }
 
-func (r *Resolver) declare(name *token.Token) (err error) {
+func (r *Resolver) declare(name *token.Token) {
 	if r.scopes.IsEmpty() {
 		return
 	}
 
 	scope := r.scopes.Peek()
-	if _, ok := scope[name.Lexeme]; ok {
-		err = fmt.Errorf("variable %q at line %d already existed in this scope", name.Lexeme, name.Line)
-		return
+	if existed, ok := scope[name.Lexeme]; ok {
+		duplicated := diag.Errorf(diag.PhaseResolve, diag.CodeDuplicateDeclaration, name.Span(), "variable %q already existed in this scope", name.Lexeme)
+		if existed.declaration != nil {
+			duplicated.Notes = append(duplicated.Notes, diag.Note{
+				Message: "previously declared here",
+				Span:    existed.declaration.Span(),
+			})
+		}
+		r.diags = append(r.diags, duplicated)
 	}
 
-	scope[name.Lexeme] = false
-	return
+	scope[name.Lexeme] = &binding{declaration: name}
 }
 
 func (r *Resolver) define(name *token.Token) {
@@ -327,7 +562,7 @@ func (r *Resolver) define(name *token.Token) {
 		return
 	}
 
-	r.scopes.Peek()[name.Lexeme] = true
+	r.scopes.Peek()[name.Lexeme] = &binding{declaration: name, defined: true}
 }
 
 func (r *Resolver) resolveLocal(v ast.Expression, name *token.Token) error {
@@ -341,45 +576,54 @@ func (r *Resolver) resolveLocal(v ast.Expression, name *token.Token) error {
 	return nil
 }
 
-func (r *Resolver) resolveFunction(v *ast.FunctionStmt, funcType FunctionType) (err error) {
+func (r *Resolver) resolveFunction(params []*token.Token, body []ast.Statement, funcType FunctionType) (err error) {
 	enclosingFuncType := r.currentFunction
+	enclosingLoopDepth := r.loopDepth
 
 	r.beginScope()
 	r.currentFunction = funcType
+	// loops out of the function can't be jumped out of
+	r.loopDepth = 0
 
 	defer func() {
 		r.endScope()
 		r.currentFunction = enclosingFuncType
+		r.loopDepth = enclosingLoopDepth
 	}()
 
-	for _, param := range v.Params {
-		err = r.declare(param)
-		if err != nil {
-			return
-		}
+	for _, param := range params {
+		r.declare(param)
 		r.define(param)
 	}
-	return r.ResolveStmts(v.Body)
+	return r.resolveStmts(body)
+}
+
+// binding is a variable declared in a scope.
+type binding struct {
+	// nil for implicit ones, like "this" and "super"
+	declaration *token.Token
+	// whether the initializer has been resolved
+	defined bool
 }
 
-type scopes []map[string]bool
+type scopes []map[string]*binding
 
 func newScopes() scopes {
 	return make(scopes, 0, 8)
 }
 
-func (s *scopes) Push(element ...map[string]bool) {
+func (s *scopes) Push(element ...map[string]*binding) {
 	*s = append(*s, element...)
 }
 
-func (s *scopes) Pop() (element map[string]bool) {
+func (s *scopes) Pop() (element map[string]*binding) {
 	element = s.Peek()
 	*s = (*s)[:len(*s)-1]
 
 	return
 }
 
-func (s *scopes) Peek() (element map[string]bool) {
+func (s *scopes) Peek() (element map[string]*binding) {
 	if s.IsEmpty() {
 		panic("stack is empty")
 	}
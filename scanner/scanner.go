@@ -1,67 +1,143 @@
 package scanner
 
 import (
-	"errors"
-	"fmt"
 	"strconv"
 	"strings"
 	"unicode/utf8"
 
+	"github.com/nanmu42/bluelox/diag"
 	"github.com/nanmu42/bluelox/token"
 )
 
 type Scanner struct {
 	source []byte
 	tokens []*token.Token
+	// comments are kept aside from tokens,
+	// so that the parser never sees them.
+	comments []*token.Token
+	// lexical errors met so far
+	diags diag.List
+	// path of the module being scanned, see SetFile
+	file string
+	// unclosed braces in each embedded expression of interpolated strings,
+	// the innermost comes last.
+	interpolations []int
 
 	start   int
 	current int
 	line    int
+	// byte offset where the current line starts
+	lineStart int
+
+	// position of the token being scanned
+	startLine   int
+	startColumn int
 }
 
 func NewScanner(source []byte) *Scanner {
 	return &Scanner{
-		source:  source,
-		tokens:  make([]*token.Token, 0),
-		start:   0,
-		current: 0,
-		line:    1,
+		source:   source,
+		tokens:   make([]*token.Token, 0),
+		comments: make([]*token.Token, 0),
+		start:    0,
+		current:  0,
+		line:     1,
 	}
 }
 
+// SetFile tells the path of the module being scanned,
+// which is recorded in tokens and diagnostics.
+// It's empty for the main script.
+func (s *Scanner) SetFile(file string) {
+	s.file = file
+}
+
+// Comments returns comments met during ScanTokens, in source order.
+func (s *Scanner) Comments() []*token.Token {
+	return s.comments
+}
+
 func (s *Scanner) isAtEnd() bool {
 	return s.current >= len(s.source)
 }
 
+// ScanTokens scans source into tokens.
+//
+// Scanning goes on after lexical errors, which are replaced by token.Illegal,
+// so that all of them are reported in one pass.
+// err is a diag.List when there is any lexical error.
 func (s *Scanner) ScanTokens() (tokens []*token.Token, err error) {
 	for !s.isAtEnd() {
-		s.start = s.current
-		err = s.scanToken()
-		if err != nil {
-			err = fmt.Errorf("scaning token: %w", err)
-			return
-		}
+		s.markStart()
+		s.scanToken()
 	}
 
+	s.markStart()
 	s.tokens = append(s.tokens, &token.Token{
 		Type:    token.EOF,
 		Lexeme:  "",
 		Literal: nil,
 		Line:    s.line,
+		Column:  s.startColumn,
+		Offset:  s.current,
+		End:     s.current,
+		File:    s.file,
 	})
 
 	tokens = s.tokens
+	err = s.diags.Err()
 	return
 }
 
-func (s *Scanner) scanToken() (err error) {
+// illegal reports a lexical error,
+// and the token being scanned becomes a token.Illegal.
+func (s *Scanner) illegal(code diag.Code, format string, args ...interface{}) {
+	s.diags = append(s.diags, diag.Errorf(diag.PhaseScan, code, s.tokenSpan(), format, args...))
+	s.addSimpleToken(token.Illegal)
+}
+
+// markStart records where the next token starts.
+func (s *Scanner) markStart() {
+	s.start = s.current
+	s.startLine = s.line
+	s.startColumn = utf8.RuneCount(s.source[s.lineStart:s.start]) + 1
+}
+
+func (s *Scanner) newline() {
+	s.line++
+	s.lineStart = s.current
+}
+
+// tokenSpan returns the span of the token being scanned.
+func (s *Scanner) tokenSpan() token.Span {
+	return token.Span{
+		Offset: s.start,
+		End:    s.current,
+		Line:   s.startLine,
+		Column: s.startColumn,
+		File:   s.file,
+	}
+}
+
+// spanAt returns the span from offset to end, both on the current line.
+func (s *Scanner) spanAt(offset, end int) token.Span {
+	return token.Span{
+		Offset: offset,
+		End:    end,
+		Line:   s.line,
+		Column: utf8.RuneCount(s.source[s.lineStart:offset]) + 1,
+		File:   s.file,
+	}
+}
+
+func (s *Scanner) scanToken() {
 	var c = s.advance()
 	switch c {
 	case ' ', '\r', '\t':
 		// relax
 		return
 	case '\n':
-		s.line++
+		s.newline()
 		return
 	case '(':
 		s.addSimpleToken(token.LeftParen)
@@ -70,28 +146,69 @@ func (s *Scanner) scanToken() (err error) {
 		s.addSimpleToken(token.RightParen)
 		return
 	case '{':
+		if depth := len(s.interpolations); depth > 0 {
+			s.interpolations[depth-1]++
+		}
 		s.addSimpleToken(token.LeftBrace)
 		return
 	case '}':
+		if depth := len(s.interpolations); depth > 0 {
+			if s.interpolations[depth-1] == 0 {
+				// the embedded expression ends, and the string goes on
+				s.interpolations = s.interpolations[:depth-1]
+				s.string()
+				return
+			}
+			s.interpolations[depth-1]--
+		}
 		s.addSimpleToken(token.RightBrace)
 		return
+	case '[':
+		s.addSimpleToken(token.LeftBracket)
+		return
+	case ']':
+		s.addSimpleToken(token.RightBracket)
+		return
 	case ',':
 		s.addSimpleToken(token.Comma)
 		return
+	case ':':
+		s.addSimpleToken(token.Colon)
+		return
 	case '.':
 		s.addSimpleToken(token.Dot)
 		return
 	case '-':
-		s.addSimpleToken(token.Minus)
+		if s.match('=') {
+			s.addSimpleToken(token.MinusEqual)
+		} else {
+			s.addSimpleToken(token.Minus)
+		}
 		return
 	case '+':
-		s.addSimpleToken(token.Plus)
+		if s.match('=') {
+			s.addSimpleToken(token.PlusEqual)
+		} else {
+			s.addSimpleToken(token.Plus)
+		}
+		return
+	case '%':
+		s.addSimpleToken(token.Percent)
+		return
+	case '?':
+		s.addSimpleToken(token.Question)
 		return
 	case ';':
 		s.addSimpleToken(token.Semicolon)
 		return
 	case '*':
-		s.addSimpleToken(token.Star)
+		if s.match('*') {
+			s.addSimpleToken(token.StarStar)
+		} else if s.match('=') {
+			s.addSimpleToken(token.StarEqual)
+		} else {
+			s.addSimpleToken(token.Star)
+		}
 		return
 	case '!':
 		if s.match('=') {
@@ -127,26 +244,30 @@ func (s *Scanner) scanToken() (err error) {
 			for s.peek() != '\n' && !s.isAtEnd() {
 				s.advance()
 			}
+			lexeme := strings.TrimRight(string(s.source[s.start:s.current]), " \t\r")
+			s.comments = append(s.comments, &token.Token{
+				Type:    token.Comment,
+				Lexeme:  lexeme,
+				Literal: nil,
+				Line:    s.startLine,
+				Column:  s.startColumn,
+				Offset:  s.start,
+				End:     s.start + len(lexeme),
+				File:    s.file,
+			})
+		} else if s.match('=') {
+			s.addSimpleToken(token.SlashEqual)
 		} else {
 			s.addSimpleToken(token.Slash)
 		}
 		return
 	case '"':
-		err = s.string()
-		if err != nil {
-			err = fmt.Errorf("scanning string at line %d: %w", s.line, err)
-			return
-		}
+		s.string()
 		return
 	}
 
 	if isDigit(c) {
-		err = s.number()
-		if err != nil {
-			err = fmt.Errorf("scanning number at line %d: %w", s.line, err)
-			return
-		}
-
+		s.number()
 		return
 	}
 
@@ -155,8 +276,7 @@ func (s *Scanner) scanToken() (err error) {
 		return
 	}
 
-	err = fmt.Errorf("unexpected character %q at line %d", c, s.line)
-	return
+	s.illegal(diag.CodeUnexpectedCharacter, "unexpected character %q", c)
 }
 
 func (s *Scanner) peek() rune {
@@ -180,7 +300,11 @@ func (s *Scanner) addToken(tokenType token.Type, literal interface{}) {
 		Type:    tokenType,
 		Lexeme:  string(text),
 		Literal: literal,
-		Line:    s.line,
+		Line:    s.startLine,
+		Column:  s.startColumn,
+		Offset:  s.start,
+		End:     s.current,
+		File:    s.file,
 	})
 }
 
@@ -214,14 +338,33 @@ func (s *Scanner) match(expected rune) bool {
 // \v   U+000B vertical tab
 // \\   U+005C backslash
 // \"   U+0022 double quote
-func (s *Scanner) string() (err error) {
-	var b strings.Builder
+// \$   U+0024 dollar sign
+//
+// Expressions are embedded in strings by "${expr}",
+// a string is split into token.Interpolation parts followed by tokens of the expressions,
+// and ends with a token.String part.
+// string is called after the opening " of a string, or the closing } of an embedded expression.
+func (s *Scanner) string() {
+	var (
+		b strings.Builder
+		// whether there is any bad escaped char
+		bad bool
+	)
 
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' {
-			s.line++
-		}
 		c := s.advance()
+		if c == '\n' {
+			s.newline()
+		}
+		if c == '$' && s.match('{') {
+			s.interpolations = append(s.interpolations, 0)
+			if bad {
+				s.addSimpleToken(token.Illegal)
+				return
+			}
+			s.addToken(token.Interpolation, b.String())
+			return
+		}
 		if c != '\\' {
 			b.WriteRune(c)
 			continue
@@ -245,27 +388,38 @@ func (s *Scanner) string() (err error) {
 			b.WriteRune('\\')
 		case '"':
 			b.WriteRune('"')
+		case '$':
+			b.WriteRune('$')
 		default:
-			err = fmt.Errorf("unexpected escaped char '\\%s'", string(s.peek()))
-			return
+			backslash := s.current - 1
+			escaped := s.advance()
+			s.diags = append(s.diags, diag.Errorf(diag.PhaseScan, diag.CodeInvalidEscape, s.spanAt(backslash, s.current), "unexpected escaped char '\\%s' in string", string(escaped)))
+			if escaped == '\n' {
+				s.newline()
+			}
+			bad = true
+			continue
 		}
 		s.advance()
 	}
 
 	if s.isAtEnd() {
-		err = errors.New("unterminated string")
+		s.illegal(diag.CodeUnterminatedString, "unterminated string")
 		return
 	}
 
 	// skip the closing "
 	s.advance()
 
-	s.addToken(token.String, b.String())
+	if bad {
+		s.addSimpleToken(token.Illegal)
+		return
+	}
 
-	return
+	s.addToken(token.String, b.String())
 }
 
-func (s *Scanner) number() (err error) {
+func (s *Scanner) number() {
 	for isDigit(s.peek()) {
 		s.advance()
 	}
@@ -283,13 +437,11 @@ func (s *Scanner) number() (err error) {
 	text := s.source[s.start:s.current]
 	value, err := strconv.ParseFloat(string(text), 64)
 	if err != nil {
-		err = fmt.Errorf("parsing float on %q: %w", text, err)
+		s.illegal(diag.CodeInvalidNumber, "parsing float on %q: %s", text, err)
 		return
 	}
 
 	s.addToken(token.Number, value)
-
-	return
 }
 
 func (s *Scanner) identifier() {
@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/nanmu42/bluelox/diag"
 	"github.com/nanmu42/bluelox/token"
 )
 
@@ -36,8 +37,8 @@ func TestScanner_ScanTokens(t *testing.T) {
 		{
 			name: "easy",
 			source: `// this is a comment
-(( )){} // grouping stuff
-!*+-/=<> <= == // operators`,
+(( )){}[]: // grouping stuff
+!*+-/ =<> <= == // operators`,
 			wantTokens: []*token.Token{
 				st(token.LeftParen, "(", 2),
 				st(token.LeftParen, "(", 2),
@@ -45,6 +46,9 @@ func TestScanner_ScanTokens(t *testing.T) {
 				st(token.RightParen, ")", 2),
 				st(token.LeftBrace, "{", 2),
 				st(token.RightBrace, "}", 2),
+				st(token.LeftBracket, "[", 2),
+				st(token.RightBracket, "]", 2),
+				st(token.Colon, ":", 2),
 
 				st(token.Bang, "!", 3),
 				st(token.Star, "*", 3),
@@ -65,7 +69,7 @@ func TestScanner_ScanTokens(t *testing.T) {
 			name: "UTF8",
 			source: `// 这是一段注释
 (( )){} // 滚滚长江东逝水
-!*+-/=<> <= == // 我能吞下剥离而不伤及身体`,
+!*+-/ =<> <= == // 我能吞下剥离而不伤及身体`,
 			wantTokens: []*token.Token{
 				st(token.LeftParen, "(", 2),
 				st(token.LeftParen, "(", 2),
@@ -179,6 +183,42 @@ print "你好，世界！"
 			},
 			wantErr: false,
 		},
+		{
+			name:   "more operators",
+			source: `% ? ** *= += -= /= //`,
+			wantTokens: []*token.Token{
+				st(token.Percent, "%", 1),
+				st(token.Question, "?", 1),
+				st(token.StarStar, "**", 1),
+				st(token.StarEqual, "*=", 1),
+				st(token.PlusEqual, "+=", 1),
+				st(token.MinusEqual, "-=", 1),
+				st(token.SlashEqual, "/=", 1),
+
+				st(token.EOF, "", 1),
+			},
+			wantErr: false,
+		},
+		{
+			name:   "string interpolation",
+			source: `"a ${ {}["k"] } b ${"${c}"}\${d}"`,
+			wantTokens: []*token.Token{
+				{Type: token.Interpolation, Lexeme: `"a ${`, Literal: "a ", Line: 1},
+				st(token.LeftBrace, "{", 1),
+				st(token.RightBrace, "}", 1),
+				st(token.LeftBracket, "[", 1),
+				{Type: token.String, Lexeme: `"k"`, Literal: "k", Line: 1},
+				st(token.RightBracket, "]", 1),
+				{Type: token.Interpolation, Lexeme: `} b ${`, Literal: " b ", Line: 1},
+				{Type: token.Interpolation, Lexeme: `"${`, Literal: "", Line: 1},
+				st(token.Identifier, "c", 1),
+				{Type: token.String, Lexeme: `}"`, Literal: "", Line: 1},
+				{Type: token.String, Lexeme: `}\${d}"`, Literal: "${d}", Line: 1},
+
+				st(token.EOF, "", 1),
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -188,7 +228,107 @@ print "你好，世界！"
 				t.Errorf("ScanTokens() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			require.Equal(t, tt.wantTokens, gotTokens)
+			require.Equal(t, tt.wantTokens, withoutPositions(gotTokens))
 		})
 	}
 }
+
+// withoutPositions clears columns and offsets,
+// which are covered by TestScanner_positions.
+func withoutPositions(tokens []*token.Token) []*token.Token {
+	for _, item := range tokens {
+		item.Column = 0
+		item.Offset = 0
+		item.End = 0
+	}
+
+	return tokens
+}
+
+func TestScanner_positions(t *testing.T) {
+	s := NewScanner([]byte("var v = \"a\nb\";\n  print \"变量\"; // 注释"))
+	gotTokens, err := s.ScanTokens()
+	require.NoError(t, err)
+
+	type position struct {
+		Lexeme       string
+		Line, Column int
+		Offset, End  int
+	}
+	var got []position
+	for _, item := range append(gotTokens, s.Comments()...) {
+		got = append(got, position{
+			Lexeme: item.Lexeme,
+			Line:   item.Line,
+			Column: item.Column,
+			Offset: item.Offset,
+			End:    item.End,
+		})
+	}
+
+	require.Equal(t, []position{
+		{"var", 1, 1, 0, 3},
+		{"v", 1, 5, 4, 5},
+		{"=", 1, 7, 6, 7},
+		{"\"a\nb\"", 1, 9, 8, 13},
+		{";", 2, 3, 13, 14},
+		{"print", 3, 3, 17, 22},
+		{"\"变量\"", 3, 9, 23, 31},
+		{";", 3, 13, 31, 32},
+		{"", 3, 20, 42, 42},
+		{"// 注释", 3, 15, 33, 42},
+	}, got)
+}
+
+func TestScanner_Comments(t *testing.T) {
+	s := NewScanner([]byte(`// this is a comment
+(( )){} // grouping stuff
+!*+-/=<> <= == // 我能吞下剥离而不伤及身体`))
+	_, err := s.ScanTokens()
+	require.NoError(t, err)
+	require.Equal(t, []*token.Token{
+		st(token.Comment, "// this is a comment", 1),
+		st(token.Comment, "// grouping stuff", 2),
+		st(token.Comment, "// 我能吞下剥离而不伤及身体", 3),
+	}, withoutPositions(s.Comments()))
+}
+
+func TestScanner_errors(t *testing.T) {
+	s := NewScanner([]byte(`var a = @;
+print "bad \q escape";
+var b = #;
+print "unterminated;`))
+	gotTokens, err := s.ScanTokens()
+	require.Error(t, err)
+
+	var diags diag.List
+	require.ErrorAs(t, err, &diags)
+
+	type brief struct {
+		Code diag.Code
+		Span string
+	}
+	var got []brief
+	for _, item := range diags {
+		got = append(got, brief{Code: item.Code, Span: item.Span.String()})
+	}
+	require.Equal(t, []brief{
+		{diag.CodeUnexpectedCharacter, "1:9"},
+		{diag.CodeInvalidEscape, "2:12"},
+		{diag.CodeUnexpectedCharacter, "3:9"},
+		{diag.CodeUnterminatedString, "4:7"},
+	}, got)
+
+	// scanning goes on, with errors replaced by illegal tokens
+	var gotTypes []token.Type
+	for _, item := range gotTokens {
+		gotTypes = append(gotTypes, item.Type)
+	}
+	require.Equal(t, []token.Type{
+		token.Var, token.Identifier, token.Equal, token.Illegal, token.Semicolon,
+		token.Print, token.Illegal, token.Semicolon,
+		token.Var, token.Identifier, token.Equal, token.Illegal, token.Semicolon,
+		token.Print, token.Illegal,
+		token.EOF,
+	}, gotTypes)
+}
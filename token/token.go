@@ -6,9 +6,53 @@ type Token struct {
 	Type    Type
 	Lexeme  string
 	Literal interface{}
-	Line    int
+	// Line where the token starts, starting at 1
+	Line int
+	// Column where the token starts, starting at 1, counted in runes
+	Column int
+	// Offset is the byte offset of the token in source, starting at 0
+	Offset int
+	// End is the byte offset right after the token
+	End int
+	// File is the path of the module where the token is, empty for the main script
+	File string
 }
 
 func (t Token) String() string {
-	return fmt.Sprintf("lexme %q with literal %s, type %s at line %d", t.Lexeme, t.Literal, t.Type, t.Line)
+	return fmt.Sprintf("lexme %q with literal %s, type %s at line %d, column %d", t.Lexeme, t.Literal, t.Type, t.Line, t.Column)
+}
+
+// Span returns where the token locates in source.
+func (t *Token) Span() Span {
+	return Span{
+		Offset: t.Offset,
+		End:    t.End,
+		Line:   t.Line,
+		Column: t.Column,
+		File:   t.File,
+	}
+}
+
+// Span locates a piece of source code.
+type Span struct {
+	// Offset is the byte offset where the span starts, starting at 0
+	Offset int
+	// End is the byte offset right after the span
+	End int
+	// Line where the span starts, starting at 1
+	Line int
+	// Column where the span starts, starting at 1, counted in runes
+	Column int
+	// File is the path of the module where the span is, empty for the main script
+	File string
+}
+
+// Through returns a span starting at s and ending at end.
+func (s Span) Through(end Span) Span {
+	s.End = end.End
+	return s
+}
+
+func (s Span) String() string {
+	return fmt.Sprintf("%d:%d", s.Line, s.Column)
 }
@@ -11,10 +11,15 @@ const (
 	RightParen
 	LeftBrace
 	RightBrace
+	LeftBracket
+	RightBracket
 	Comma
+	Colon
 	Dot
 	Minus
+	Percent
 	Plus
+	Question
 	Semicolon
 	Slash
 	Star
@@ -31,6 +36,11 @@ const (
 	GreaterEqual
 	Less
 	LessEqual
+	MinusEqual
+	PlusEqual
+	SlashEqual
+	StarEqual
+	StarStar
 
 	OneOrTwoCharacterTokenEnd
 
@@ -38,6 +48,9 @@ const (
 
 	Identifier
 	String
+	// Interpolation is a part of string followed by an embedded expression,
+	// like `"a ${` or `} b ${` in "a ${x} b ${y}".
+	Interpolation
 	Number
 
 	LiteralEnd
@@ -45,42 +58,63 @@ const (
 	KeywordStart
 
 	And
+	Break
+	Catch
 	Class
+	Continue
 	Else
+	Export
 	False
+	Finally
 	Fun
 	For
 	If
+	Import
 	Nil
 	Or
 	Print
 	Return
 	Super
 	This
+	Throw
 	True
+	Try
 	Var
 	While
 
 	KeywordEnd
 
+	// Comment is trivia, it never reaches the parser.
+	Comment
+	// Illegal replaces what the scanner fails to recognize.
+	Illegal
+
 	EOF
 )
 
 var KeywordMapping = map[string]Type{
-	"and":    And,
-	"class":  Class,
-	"else":   Else,
-	"false":  False,
-	"for":    For,
-	"fun":    Fun,
-	"if":     If,
-	"nil":    Nil,
-	"or":     Or,
-	"print":  Print,
-	"return": Return,
-	"super":  Super,
-	"this":   This,
-	"true":   True,
-	"var":    Var,
-	"while":  While,
+	"and":      And,
+	"break":    Break,
+	"catch":    Catch,
+	"class":    Class,
+	"continue": Continue,
+	"else":     Else,
+	"export":   Export,
+	"false":    False,
+	"finally":  Finally,
+	"for":      For,
+	"fun":      Fun,
+	"if":       If,
+	"import":   Import,
+	"nil":      Nil,
+	"or":       Or,
+	"print":    Print,
+	"return":   Return,
+	"super":    Super,
+	"this":     This,
+	"throw":    Throw,
+	"true":     True,
+	"try":      Try,
+	"var":      Var,
+	"while":    While,
 }
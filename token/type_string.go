@@ -13,53 +13,74 @@ func _() {
 	_ = x[RightParen-2]
 	_ = x[LeftBrace-3]
 	_ = x[RightBrace-4]
-	_ = x[Comma-5]
-	_ = x[Dot-6]
-	_ = x[Minus-7]
-	_ = x[Plus-8]
-	_ = x[Semicolon-9]
-	_ = x[Slash-10]
-	_ = x[Star-11]
-	_ = x[SingleCharacterTokenEnd-12]
-	_ = x[OneOrTwoCharacterTokenStart-13]
-	_ = x[Bang-14]
-	_ = x[BangEqual-15]
-	_ = x[Equal-16]
-	_ = x[EqualEqual-17]
-	_ = x[Greater-18]
-	_ = x[GreaterEqual-19]
-	_ = x[Less-20]
-	_ = x[LessEqual-21]
-	_ = x[OneOrTwoCharacterTokenEnd-22]
-	_ = x[LiteralStart-23]
-	_ = x[Identifier-24]
-	_ = x[String-25]
-	_ = x[Number-26]
-	_ = x[LiteralEnd-27]
-	_ = x[KeywordStart-28]
-	_ = x[And-29]
-	_ = x[Class-30]
-	_ = x[Else-31]
-	_ = x[False-32]
-	_ = x[Fun-33]
-	_ = x[For-34]
-	_ = x[If-35]
-	_ = x[Nil-36]
-	_ = x[Or-37]
-	_ = x[Print-38]
-	_ = x[Return-39]
-	_ = x[Super-40]
-	_ = x[This-41]
-	_ = x[True-42]
-	_ = x[Var-43]
-	_ = x[While-44]
-	_ = x[KeywordEnd-45]
-	_ = x[EOF-46]
+	_ = x[LeftBracket-5]
+	_ = x[RightBracket-6]
+	_ = x[Comma-7]
+	_ = x[Colon-8]
+	_ = x[Dot-9]
+	_ = x[Minus-10]
+	_ = x[Percent-11]
+	_ = x[Plus-12]
+	_ = x[Question-13]
+	_ = x[Semicolon-14]
+	_ = x[Slash-15]
+	_ = x[Star-16]
+	_ = x[SingleCharacterTokenEnd-17]
+	_ = x[OneOrTwoCharacterTokenStart-18]
+	_ = x[Bang-19]
+	_ = x[BangEqual-20]
+	_ = x[Equal-21]
+	_ = x[EqualEqual-22]
+	_ = x[Greater-23]
+	_ = x[GreaterEqual-24]
+	_ = x[Less-25]
+	_ = x[LessEqual-26]
+	_ = x[MinusEqual-27]
+	_ = x[PlusEqual-28]
+	_ = x[SlashEqual-29]
+	_ = x[StarEqual-30]
+	_ = x[StarStar-31]
+	_ = x[OneOrTwoCharacterTokenEnd-32]
+	_ = x[LiteralStart-33]
+	_ = x[Identifier-34]
+	_ = x[String-35]
+	_ = x[Interpolation-36]
+	_ = x[Number-37]
+	_ = x[LiteralEnd-38]
+	_ = x[KeywordStart-39]
+	_ = x[And-40]
+	_ = x[Break-41]
+	_ = x[Catch-42]
+	_ = x[Class-43]
+	_ = x[Continue-44]
+	_ = x[Else-45]
+	_ = x[Export-46]
+	_ = x[False-47]
+	_ = x[Finally-48]
+	_ = x[Fun-49]
+	_ = x[For-50]
+	_ = x[If-51]
+	_ = x[Import-52]
+	_ = x[Nil-53]
+	_ = x[Or-54]
+	_ = x[Print-55]
+	_ = x[Return-56]
+	_ = x[Super-57]
+	_ = x[This-58]
+	_ = x[Throw-59]
+	_ = x[True-60]
+	_ = x[Try-61]
+	_ = x[Var-62]
+	_ = x[While-63]
+	_ = x[KeywordEnd-64]
+	_ = x[Comment-65]
+	_ = x[Illegal-66]
+	_ = x[EOF-67]
 }
 
-const _Type_name = "SingleCharacterTokenStartLeftParenRightParenLeftBraceRightBraceCommaDotMinusPlusSemicolonSlashStarSingleCharacterTokenEndOneOrTwoCharacterTokenStartBangBangEqualEqualEqualEqualGreaterGreaterEqualLessLessEqualOneOrTwoCharacterTokenEndLiteralStartIdentifierStringNumberLiteralEndKeywordStartAndClassElseFalseFunForIfNilOrPrintReturnSuperThisTrueVarWhileKeywordEndEOF"
+const _Type_name = "SingleCharacterTokenStartLeftParenRightParenLeftBraceRightBraceLeftBracketRightBracketCommaColonDotMinusPercentPlusQuestionSemicolonSlashStarSingleCharacterTokenEndOneOrTwoCharacterTokenStartBangBangEqualEqualEqualEqualGreaterGreaterEqualLessLessEqualMinusEqualPlusEqualSlashEqualStarEqualStarStarOneOrTwoCharacterTokenEndLiteralStartIdentifierStringInterpolationNumberLiteralEndKeywordStartAndBreakCatchClassContinueElseExportFalseFinallyFunForIfImportNilOrPrintReturnSuperThisThrowTrueTryVarWhileKeywordEndCommentIllegalEOF"
 
-var _Type_index = [...]uint16{0, 25, 34, 44, 53, 63, 68, 71, 76, 80, 89, 94, 98, 121, 148, 152, 161, 166, 176, 183, 195, 199, 208, 233, 245, 255, 261, 267, 277, 289, 292, 297, 301, 306, 309, 312, 314, 317, 319, 324, 330, 335, 339, 343, 346, 351, 361, 364}
+var _Type_index = [...]uint16{0, 25, 34, 44, 53, 63, 74, 86, 91, 96, 99, 104, 111, 115, 123, 132, 137, 141, 164, 191, 195, 204, 209, 219, 226, 238, 242, 251, 261, 270, 280, 289, 297, 322, 334, 344, 350, 363, 369, 379, 391, 394, 399, 404, 409, 417, 421, 427, 432, 439, 442, 445, 447, 453, 456, 458, 463, 469, 474, 478, 483, 487, 490, 493, 498, 508, 515, 522, 525}
 
 func (i Type) String() string {
 	if i < 0 || i >= Type(len(_Type_index)-1) {
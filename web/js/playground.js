@@ -94,7 +94,7 @@ function PlaygroundOutput(el) {
 
 (function() {
     function lineHighlight(error) {
-        const regex = / at line ([0-9]+)/g
+        const regex = /main\.lox:([0-9]+):[0-9]+: /g
         let r = regex.exec(error)
         while (r) {
             $('.lines div')
@@ -218,8 +218,13 @@ function PlaygroundOutput(el) {
             window.writeOutput = PlaygroundOutput(output)
         }
 
-        function fmtAnd(run) {
-            // Note(nanmu42): format is hard, let's leave it for now.
+        async function fmtAnd(run) {
+            try {
+                setBody(await window.loxfmt(body()));
+            } catch (e) {
+                setError(e);
+                return;
+            }
             run();
         }
 